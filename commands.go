@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/id3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/inspect"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3"
+)
+
+// inspectCommand prints the format, duration, bitrate, tags, chapters and
+// VBR header of audio files.
+func inspectCommand(args []string) {
+	commandFlags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := commandFlags.Bool("json", false, "Print the information as json")
+	commandFlags.Usage = func() {
+		fmt.Fprintf(commandFlags.Output(), "Usage: %s inspect [-json] file...\n", os.Args[0])
+		commandFlags.PrintDefaults()
+	}
+	commandFlags.Parse(args)
+	if commandFlags.NArg() == 0 {
+		commandFlags.Usage()
+		os.Exit(2)
+	}
+	var infos []*inspect.Info
+	for _, path := range commandFlags.Args() {
+		info, err := inspect.File(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		infos = append(infos, info)
+	}
+	if *asJSON {
+		content, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(content))
+		return
+	}
+	for _, info := range infos {
+		fmt.Print(info)
+	}
+}
+
+// splitCommand cuts an MP3 file at frame boundaries, at timestamps, at its
+// chapters or into equal parts.
+func splitCommand(args []string) {
+	commandFlags := flag.NewFlagSet("split", flag.ExitOnError)
+	at := commandFlags.String("at", "", "Comma separated timestamps to cut at, like 10:30,1:02:00 or 90s")
+	chapters := commandFlags.Bool("chapters", false, "Cut at the chapters of the ID3 tag, with the chapter titles as title")
+	parts := commandFlags.Int("parts", 0, "Number of parts of equal length to cut")
+	length := commandFlags.Duration("length", 0, "Maximum length of the parts of equal length to cut")
+	output := commandFlags.String("output", "", "Name of the parts without extension, numbered from 1, defaults to the input name")
+	force := commandFlags.Bool("force", false, "Overwrite existing parts")
+	commandFlags.Usage = func() {
+		fmt.Fprintf(commandFlags.Output(), "Usage: %s split (-at timestamps | -chapters | -parts n | -length d) [-output name] file.mp3\n", os.Args[0])
+		commandFlags.PrintDefaults()
+	}
+	commandFlags.Parse(args)
+	if commandFlags.NArg() != 1 {
+		commandFlags.Usage()
+		os.Exit(2)
+	}
+	inpath := commandFlags.Arg(0)
+	if len(*output) == 0 {
+		*output = strings.TrimSuffix(inpath, ".mp3")
+	}
+
+	var split []mergemp3.Part
+	switch {
+	case len(*at) > 0:
+		var cuts []time.Duration
+		for _, s := range strings.Split(*at, ",") {
+			cut, err := parseTimestamp(strings.TrimSpace(s))
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(cuts) > 0 && cut <= cuts[len(cuts)-1] {
+				log.Fatalf("The timestamps of -at must be in order, %v comes after %v", cut, cuts[len(cuts)-1])
+			}
+			cuts = append(cuts, cut)
+		}
+		split = mergemp3.SplitAt(cuts)
+	case *chapters:
+		tag, err := id3.ReadFile(inpath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(tag.Chapters) == 0 {
+			log.Fatalf("%s has no chapters", inpath)
+		}
+		split = mergemp3.SplitChapters(tag.Chapters)
+	case *parts > 0 || *length > 0:
+		f, err := os.Open(inpath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		duration := mergemp3.Duration(f)
+		f.Close()
+		n := *parts
+		if *length > 0 {
+			n = int((duration + *length - 1) / *length)
+		}
+		split = mergemp3.SplitEqual(duration, n)
+	default:
+		commandFlags.Usage()
+		os.Exit(2)
+	}
+	for i := range split {
+		split[i].Path = fmt.Sprintf("%s.%0*d.mp3", *output, len(strconv.Itoa(len(split))), i+1)
+	}
+
+	results, err := mergemp3.Split(context.Background(), inpath, split, mergemp3.Options{
+		Force:   *force,
+		Problem: func(p mergemp3.Problem) { fmt.Printf("• %v\n", p) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, result := range results {
+		fmt.Printf("Part written to file: %v\n", split[i].Path)
+		fmt.Printf("• %v\n", result)
+	}
+}
+
+// parseTimestamp parses a timestamp like 1:02:03.5, 10:30 or a duration
+// like 90s.
+func parseTimestamp(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	var d time.Duration
+	fields := strings.Split(s, ":")
+	if len(fields) > 3 {
+		return 0, errors.New("Invalid timestamp " + s + ", expected [hh:]mm:ss")
+	}
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil || value < 0 || i > 0 && value >= 60 {
+			return 0, errors.New("Invalid timestamp " + s + ", expected [hh:]mm:ss")
+		}
+		d = d*60 + time.Duration(value*float64(time.Second))
+	}
+	return d, nil
+}
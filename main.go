@@ -2,51 +2,817 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	texttospeech "cloud.google.com/go/texttospeech/apiv1"
 	"github.com/alexandervantrijffel/goutil/errorcheck"
 	"github.com/alexandervantrijffel/goutil/logging"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/audiostats"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/dsp"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/encoder"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/id3"
 	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/splice"
 	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/ssmltext"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/transcript"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/tts"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"google.golang.org/api/option"
 	texttospeechpb "google.golang.org/genproto/googleapis/cloud/texttospeech/v1"
 )
 
-func main() {
-	logging.InitWith("hackernewseverywhere-cli", false)
-	ctx := context.Background()
-	client, err := texttospeech.NewClient(ctx)
+var (
+	linkPolicy     = flag.String("links", "silent", "How links are read: silent, announce or collect")
+	linksFile      = flag.String("links-file", "links.json", "File to write the links to that are collected with -links collect")
+	footnotePolicy = flag.String("footnotes", "drop", "Where footnote bodies are read: drop, section or article")
+	codePolicy     = flag.String("code", "skip", "How code blocks are read: skip, read or summarize")
+	// Wavenet male voice:         "en-US-Wavenet-D",
+	// Wavenet female voice: en-US-Wavenet-C
+	// Standard voice: en-US-Standard-B
+	defaultVoice   = flag.String("voice", "en-US-Wavenet-D", "Name of the voice for content without a voice assignment")
+	output         = flag.String("output", "output", "Name of the output files without extension")
+	encoding       = flag.String("encoding", "linear16", "Audio encoding to synthesize and merge as it is when -format is empty: mp3 or linear16")
+	format         = flag.String("format", "wav", "Format to encode the processed LINEAR16 audio to once: wav, mp3, aac or opus. Empty skips the encoder and merges the -encoding files")
+	encoderTool    = flag.String("encoder", "auto", "Tool that encodes -format and the renditions: auto, ffmpeg or lame")
+	bitRate        = flag.Int("bitrate", 128, "Bitrate in kbps of the -format encoding")
+	renditions     renditionsFlag
+	transcripts    = flag.Bool("transcripts", true, "Write WebVTT and SRT transcripts next to the audio")
+	marks          = flag.String("marks", "none", "Where to time the text for the alignment file: none, sentences or words")
+	ttsEndpoint    = flag.String("tts-endpoint", "", "Address of the Text-to-Speech REST API that is used with -marks, an http:// address is called without authentication")
+	chapterPolicy  = flag.String("chapters", "sections", "Where to start the chapters of MP3 output: none, stories or sections")
+	expandAudio    = flag.Bool("expand-audio", true, "Play the <audio> elements of SSML input from local files instead of letting the API fetch them")
+	assetDir       = flag.String("assets", "", "Directory with the audio files that <audio> sources are looked up in by file name")
+	audioCache     = flag.String("audio-cache", "", "Directory to download the <audio> sources to that are not in the assets directory")
+	music          = flag.String("music", "", "WAV or MP3 file to loop as music bed under the speech, requires -encoding linear16")
+	sampleRate     = flag.Int("sample-rate", 0, "Sample rate in Hz of LINEAR16 output, 0 keeps the rate of the voice")
+	channels       = flag.Int("channels", 0, "Number of channels of LINEAR16 output, 0 keeps the channels of the voice")
+	musicLevel     = flag.Float64("music-level", -20, "Level of the music bed in dB when there is no speech")
+	musicDuckLevel = flag.Float64("music-duck-level", -32, "Level of the music bed in dB during speech")
+	musicFade      = flag.Duration("music-fade", 3*time.Second, "Duration of the fades at the start and end of the music bed")
+	trim           = flag.Bool("trim", true, "Trim the silence around the synthesized LINEAR16 chunks and join them with the gaps below")
+	silenceLevel   = flag.Float64("silence-level", -50, "Level in dB below which audio counts as silence when trimming")
+	paragraphGap   = flag.Duration("paragraph-gap", 800*time.Millisecond, "Pause after every paragraph, also the gap between chunks that join at a paragraph")
+	sectionGap     = flag.Duration("section-gap", 1500*time.Millisecond, "Gap before a section or heading")
+	storyGap       = flag.Duration("story-gap", 2500*time.Millisecond, "Gap before a story")
+	repair         = flag.Bool("repair", true, "Drop corrupt MP3 frames and strip stray tags and junk when merging instead of failing")
+	join           = flag.String("join", "crossfade", "How LINEAR16 segments are joined: crossfade, zero-crossing or none")
+	crossfade      = flag.Duration("crossfade", 10*time.Millisecond, "Duration of the crossfade, or the longest search for a zero crossing, at a join")
+	normalize      = flag.Bool("normalize", true, "Normalize the loudness of LINEAR16 output and of the spliced assets")
+	loudness       = flag.Float64("loudness", -16, "Target loudness in LUFS of the normalized audio")
+	truePeak       = flag.Float64("true-peak", -1, "Maximum true peak in dBTP of the normalized audio")
+	metadataFile   = flag.String("metadata", "", "JSON file with the episode metadata for the ID3 tag, the tag flags take precedence")
+	id3Version     = flag.Int("id3-version", 4, "ID3v2 version of the tag written to MP3 output: 3 or 4")
+	title          = flag.String("title", "", "Title of the episode, defaults to the output name")
+	artist         = flag.String("artist", "", "Artist of the episode")
+	album          = flag.String("album", "", "Album, the name of the podcast")
+	track          = flag.String("track", "", "Track number of the episode")
+	date           = flag.String("date", "", "Release date of the episode as yyyy-mm-dd")
+	genre          = flag.String("genre", "Podcast", "Genre of the episode")
+	comment        = flag.String("comment", "", "Comment, like a description of the episode")
+	url            = flag.String("url", "", "Link to the web page of the episode")
+	cover          = flag.String("cover", "", "PNG or JPEG image to embed as cover art")
+	voiceRules     voiceRulesFlag
+	intro          pathsFlag
+	between        pathsFlag
+	outro          pathsFlag
+	languageVoices = languageVoicesFlag{
+		"de": "de-DE-Wavenet-B",
+		"es": "es-ES-Standard-A",
+		"fr": "fr-FR-Wavenet-B",
+		"it": "it-IT-Wavenet-C",
+		"nl": "nl-NL-Wavenet-B",
+	}
+)
+
+func init() {
+	flag.Var(&voiceRules, "voice-rule", "Assign a voice to matching blocks as selector=voice, e.g. blockquote=en-US-Wavenet-C. Can be repeated")
+	flag.Var(&renditions, "rendition", "Encode an output from the same audio, as format[,bitrate=kbps][,channels=n][,rate=Hz][,name=suffix][,title=text][,comment=text], e.g. mp3,bitrate=48,channels=1. The title and comment replace those of the episode in its tag and cannot contain a comma. Can be repeated, implies the -format pipeline")
+	flag.Var(&intro, "intro", "Audio file to play before the speech, like a jingle or sponsor read. Can be repeated")
+	flag.Var(&between, "between", "Audio file to play between stories, which start at <article> elements. Can be repeated")
+	flag.Var(&outro, "outro", "Audio file to play after the speech. Can be repeated")
+	flag.Var(&languageVoices, "language-voice", "Read paragraphs in a language with a voice as language=voice, e.g. de=de-DE-Wavenet-A. Can be repeated, use language= to read a language with the default voice")
+}
+
+// renditionsFlag collects the renditions from repeated -rendition flags.
+type renditionsFlag []encoder.Rendition
+
+func (f *renditionsFlag) String() string {
+	var names []string
+	for _, r := range *f {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (f *renditionsFlag) Set(value string) error {
+	r, err := encoder.ParseRendition(value)
 	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
+
+// pathsFlag collects the paths of a repeated flag.
+type pathsFlag []string
+
+func (f *pathsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *pathsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// languageVoicesFlag maps ISO 639-1 language codes to voice names.
+type languageVoicesFlag map[string]string
+
+func (f languageVoicesFlag) String() string {
+	var voices []string
+	for language, voice := range f {
+		voices = append(voices, language+"="+voice)
+	}
+	sort.Strings(voices)
+	return strings.Join(voices, ", ")
+}
+
+func (f languageVoicesFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return fmt.Errorf("Language voice '%s' is not formatted as language=voice", value)
+	}
+	if len(parts[1]) == 0 {
+		delete(f, parts[0])
+		return nil
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+// voiceRulesFlag collects the voice rules from repeated -voice-rule flags.
+type voiceRulesFlag []ssmltext.VoiceRule
+
+func (f *voiceRulesFlag) String() string {
+	var rules []string
+	for _, r := range *f {
+		rules = append(rules, r.Selector+"="+r.Voice)
+	}
+	return strings.Join(rules, ", ")
+}
+
+func (f *voiceRulesFlag) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("Voice rule '%s' is not formatted as selector=voice", value)
+	}
+	*f = append(*f, ssmltext.VoiceRule{Selector: value[:i], Voice: value[i+1:]})
+	return nil
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			inspectCommand(os.Args[2:])
+			return
+		case "split":
+			splitCommand(os.Args[2:])
+			return
+		}
+	}
+	flag.Parse()
+	// -format is a single rendition with the output name.
+	if len(*format) > 0 {
+		renditions = append(renditionsFlag{{Format: *format, BitRate: *bitRate}}, renditions...)
+		if *format == "wav" {
+			renditions[0].BitRate = 0
+		}
+	}
+	if err := encoder.CheckPaths(*output, renditions); err != nil {
 		log.Fatal(err)
 	}
+	logging.InitWith("hackernewseverywhere-cli", false)
+	ctx := context.Background()
 	content, _ := ioutil.ReadAll(os.Stdin)
 	if len(content) == 0 {
 		log.Fatal("No content! Please pipe content to me")
 		return
 	}
-	chunks, err := ssmltext.MakeChunks(string(content), 5000)
+	var err error
+	opts := ssmltext.Options{
+		MaxChunkChars:  5000,
+		ParagraphPause: *paragraphGap,
+		SectionPause:   *sectionGap,
+		StoryPause:     *storyGap,
+		ExpandAudio:    *expandAudio,
+		VoiceRules:     voiceRules,
+		Language:       strings.ToLower(strings.SplitN(*defaultVoice, "-", 2)[0]),
+		LanguageVoices: languageVoices,
+	}
+	if opts.Links, err = ssmltext.ParseLinkPolicy(*linkPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if opts.Footnotes, err = ssmltext.ParseFootnotePolicy(*footnotePolicy); err != nil {
+		log.Fatal(err)
+	}
+	if opts.Code, err = ssmltext.ParseCodePolicy(*codePolicy); err != nil {
+		log.Fatal(err)
+	}
+	if opts.Marks, err = ssmltext.ParseMarkPolicy(*marks); err != nil {
+		log.Fatal(err)
+	}
+	if opts.Chapters, err = ssmltext.ParseChapterPolicy(*chapterPolicy); err != nil {
+		log.Fatal(err)
+	}
+	// Only the v1beta1 REST API returns the time points of marks.
+	var client *texttospeech.Client
+	var markClient *tts.Client
+	if opts.Marks != ssmltext.MarksNone {
+		markClient = newMarkClient(ctx)
+	} else if client, err = texttospeech.NewClient(ctx); err != nil {
+		log.Fatal(err)
+	}
+	doc, err := ssmltext.MakeDocument(string(content), opts)
 	errorcheck.CheckLogFatal(err, "No content to synthesize, please pipe text to me")
-	if len(chunks) == 1 {
-		SynthesizeSsmlToFile(client, ctx, chunks[0], "output.mp3")
-		return
+	if len(doc.Links) > 0 {
+		writeLinks(*linksFile, doc.Links)
 	}
+	chunks := doc.Chunks
+	_, extension := audioEncoding()
 	var sourceFiles []string
+	var timepoints []map[string]time.Duration
+	played := make(map[int]bool)
+	resolver := &splice.Resolver{AssetDir: *assetDir, CacheDir: *audioCache}
 	for i, c := range chunks {
-		src := strconv.Itoa(i) + ".mp3"
-		SynthesizeSsmlToFile(client, ctx, c, src)
+		src := strconv.Itoa(i) + extension
 		sourceFiles = append(sourceFiles, src)
+		if c.Audio != nil && playAudio(ctx, resolver, c.Audio, src) {
+			played[i] = true
+			timepoints = append(timepoints, nil)
+			continue
+		}
+		if markClient != nil {
+			timepoints = append(timepoints, synthesizeWithMarks(markClient, ctx, c.Ssml, c.Voice, src))
+		} else {
+			SynthesizeSsmlToFile(client, ctx, c.Ssml, c.Voice, src)
+		}
+	}
+	var storyStarts []int
+	for i, c := range chunks {
+		if c.Starts == ssmltext.BoundaryStory {
+			storyStarts = append(storyStarts, i)
+		}
+	}
+	if len(between) > 0 && len(storyStarts) == 0 {
+		logging.Info("No chunk starts a story, -between assets are not played")
+	}
+	segments := splice.Plan(sourceFiles, storyStarts, splice.Assets{Intro: intro, Between: between, Outro: outro})
+	for i, s := range segments {
+		if s.Chunk >= 0 && played[s.Chunk] {
+			segments[i].Asset = true
+		}
+	}
+	// The copies of the assets are made in a directory of this run.
+	workDir, err := ioutil.TempDir("", "hackernewseverywhere")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(workDir)
+	if extension == ".wav" {
+		if _, err = splice.Conform(ctx, segments, wav.Format{SampleRate: *sampleRate, Channels: *channels}, workDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := splice.Check(segments); err != nil {
+		log.Fatal(err)
+	}
+	if *trim {
+		paceChunks(segments, chunks, timepoints)
+	}
+	if *normalize && extension == ".wav" {
+		normalizeAssets(segments, workDir)
+	}
+	// The transcripts and alignment are timed over all segments, the assets
+	// are chunks without paragraphs.
+	timeline := make([]ssmltext.Chunk, len(segments))
+	var timelinePoints []map[string]time.Duration
+	chunkInputs := make([]int, len(chunks))
+	for i, s := range segments {
+		if s.Chunk < 0 {
+			timelinePoints = append(timelinePoints, nil)
+			continue
+		}
+		timeline[i] = chunks[s.Chunk]
+		chunkInputs[s.Chunk] = i
+		var points map[string]time.Duration
+		if s.Chunk < len(timepoints) {
+			points = timepoints[s.Chunk]
+		}
+		timelinePoints = append(timelinePoints, points)
+	}
+	outpath := *output + extension
+	// A single file is merged as well, for the statistics of the output.
+	var result *audiostats.Result
+	if extension == ".wav" {
+		if result, err = wav.MergeWith(outpath, splice.Paths(segments), joiner()); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		opts := mergemp3.Options{
+			Force:    true,
+			Header:   reservedTag(timeline, doc.Chapters, chunkInputs),
+			Progress: func(p mergemp3.Progress) { fmt.Println("+", p.Name) },
+			Repair:   *repair,
+			Problem:  func(p mergemp3.Problem) { fmt.Printf("• %v\n", p) },
+		}
+		if result, err = mergemp3.Merge(ctx, outpath, splice.Paths(segments), opts); err != nil {
+			log.Fatal(err)
+		}
 	}
-	mergemp3.Merge("output.mp3", sourceFiles, true, false)
 	for _, s := range sourceFiles {
 		os.Remove(s)
 	}
+	fmt.Printf("Audio content written to file: %v\n", outpath)
+	fmt.Printf("• %v\n", result)
+	starts, durations := result.Starts(), result.Durations()
+	cues := transcript.FromChunks(timeline, starts, durations)
+	if len(*music) > 0 {
+		mixMusic(ctx, outpath, *music, cues)
+	}
+	if *normalize {
+		normalizeFile(outpath)
+	}
+	tag := episodeTag(cues)
+	tag.Chapters = episodeChapters(doc.Chapters, chunkInputs, result)
+	outputs, tags := []string{outpath}, []*id3.Tag{tag}
+	if len(renditions) > 0 {
+		outputs, tags = encodeRenditions(ctx, outpath, tag)
+	}
+	for i, o := range outputs {
+		if filepath.Ext(o) != ".mp3" {
+			continue
+		}
+		if err := id3.WriteFile(o, tags[i]); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("• Tagged %v as '%v'\n", o, tags[i].Title)
+	}
+	if *transcripts {
+		writeTranscripts(*output, cues)
+	}
+	if markClient != nil {
+		alignmentFile := *output + ".alignment.json"
+		if err := transcript.WriteAlignment(alignmentFile, transcript.Align(timeline, starts, durations, timelinePoints)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Alignment written to file: %v\n", alignmentFile)
+	}
+}
+
+// encodeRenditions encodes the processed WAV file to every rendition and
+// returns the paths of the encoded files with their tags. The WAV file is
+// removed when it is not one of them. The tag is written to the renditions
+// that ffmpeg encodes to another format than MP3, MP3 files get an ID3 tag
+// afterwards.
+func encodeRenditions(ctx context.Context, wavPath string, episode *id3.Tag) ([]string, []*id3.Tag) {
+	var outputs []string
+	var tags []*id3.Tag
+	for _, r := range renditions {
+		enc, err := r.Encoder(*encoderTool)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tag := renditionTag(r, episode)
+		if f, ok := enc.(*encoder.FFmpeg); ok && f.Format != "mp3" {
+			f.Metadata = map[string]string{
+				"title":   tag.Title,
+				"artist":  tag.Artist,
+				"album":   tag.Album,
+				"track":   tag.Track,
+				"date":    tag.Date,
+				"genre":   tag.Genre,
+				"comment": tag.Comment,
+			}
+		}
+		outpath := r.Path(*output, enc.Extension())
+		if err := enc.Encode(ctx, wavPath, outpath); err != nil {
+			log.Fatal(err)
+		}
+		outputs = append(outputs, outpath)
+		tags = append(tags, tag)
+		fmt.Printf("Audio encoded to file: %v\n", outpath)
+	}
+	for _, o := range outputs {
+		if o == wavPath {
+			return outputs, tags
+		}
+	}
+	os.Remove(wavPath)
+	return outputs, tags
+}
+
+// renditionTag returns the episode tag with the title and comment that the
+// rendition replaces.
+func renditionTag(r encoder.Rendition, episode *id3.Tag) *id3.Tag {
+	tag := *episode
+	if len(r.Title) > 0 {
+		tag.Title = r.Title
+	}
+	if len(r.Comment) > 0 {
+		tag.Comment = r.Comment
+	}
+	return &tag
+}
+
+// joiner returns the joiner that -join selects.
+func joiner() wav.Joiner {
+	switch *join {
+	case "crossfade":
+		return dsp.Crossfade(*crossfade)
+	case "zero-crossing":
+		return dsp.ZeroCrossing(*crossfade)
+	case "none":
+		return wav.Append
+	}
+	log.Fatalf("Unknown join '%s', expected crossfade, zero-crossing or none", *join)
+	return nil
 }
 
-func SynthesizeSsmlToFile(client *texttospeech.Client, ctx context.Context, ssml, destinationFile string) {
+// paceChunks trims the silence around the synthesized chunks and adds the
+// gap for the boundary with the segment after them, so that the pauses at
+// the joins match the pauses within the chunks. The time points of the marks
+// in a chunk move forward by the leading silence that was cut.
+func paceChunks(segments []splice.Segment, chunks []ssmltext.Chunk, timepoints []map[string]time.Duration) {
+	for i, s := range segments {
+		if s.Asset || filepath.Ext(s.Path) != ".wav" {
+			continue
+		}
+		audio, err := wav.ReadFile(s.Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		audio, lead := dsp.TrimSilence(audio, dsp.DB(*silenceLevel), 20*time.Millisecond)
+		if s.Chunk < len(timepoints) {
+			for name, at := range timepoints[s.Chunk] {
+				if at -= lead; at < 0 {
+					at = 0
+				}
+				timepoints[s.Chunk][name] = at
+			}
+		}
+		var gap time.Duration
+		if i+1 < len(segments) {
+			gap = *paragraphGap
+			if next := segments[i+1]; next.Chunk >= 0 {
+				switch chunks[next.Chunk].Starts {
+				case ssmltext.BoundarySection:
+					gap = *sectionGap
+				case ssmltext.BoundaryStory:
+					gap = *storyGap
+				}
+			}
+			audio = dsp.Pad(audio, gap)
+		}
+		// The pause after the last paragraph is now the gap, for the
+		// transcripts.
+		if paragraphs := chunks[s.Chunk].Paragraphs; len(paragraphs) > 0 {
+			last := &paragraphs[len(paragraphs)-1]
+			last.Pause += gap - *paragraphGap
+			if last.Pause < 0 {
+				last.Pause = 0
+			}
+		}
+		if err := wav.WriteFile(s.Path, audio); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// normalizeFile normalizes the loudness of the WAV file at path. MP3 output
+// is left as it is.
+func normalizeFile(path string) {
+	if filepath.Ext(path) != ".wav" {
+		logging.Info("Loudness normalization requires LINEAR16 audio, the MP3 output is not normalized")
+		return
+	}
+	audio, err := wav.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gain := dsp.Normalize(audio, *loudness, *truePeak)
+	if err := wav.WriteFile(path, audio); err != nil {
+		log.Fatal(err)
+	}
+	logging.Infof("Normalized %s to %.1f LUFS with a gain of %.1f dB", path, *loudness, gain)
+}
+
+// normalizeAssets normalizes the loudness of the assets among the segments,
+// so that they match the speech. The chunks that play an <audio> element are
+// normalized in place, the other assets are copied to dir first.
+func normalizeAssets(segments []splice.Segment, dir string) {
+	for i, s := range segments {
+		if !s.Asset {
+			continue
+		}
+		if s.Chunk < 0 {
+			audio, err := wav.ReadFile(s.Path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			segments[i].Path = filepath.Join(dir, fmt.Sprintf("asset%d.wav", i))
+			if err := wav.WriteFile(segments[i].Path, audio); err != nil {
+				log.Fatal(err)
+			}
+		}
+		normalizeFile(segments[i].Path)
+	}
+}
+
+// mixMusic mixes the music bed under the speech in the WAV file at path,
+// ducking it while the cues are spoken.
+func mixMusic(ctx context.Context, path, musicPath string, cues []transcript.Cue) {
+	if filepath.Ext(path) != ".wav" {
+		log.Fatal("A music bed can only be mixed into LINEAR16 audio, use -encoding linear16")
+	}
+	speech, err := wav.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bed, err := splice.Load(ctx, musicPath, speech.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var spans []dsp.Span
+	for _, c := range cues {
+		spans = append(spans, dsp.Span{Start: c.Start, End: c.End})
+	}
+	ducking := dsp.Ducking{
+		Gain:     dsp.DB(*musicLevel),
+		DuckGain: dsp.DB(*musicDuckLevel),
+		Attack:   300 * time.Millisecond,
+		Release:  800 * time.Millisecond,
+		FadeIn:   *musicFade,
+		FadeOut:  *musicFade,
+	}
+	if err := dsp.MixBed(speech, bed, spans, ducking); err != nil {
+		log.Fatal(err)
+	}
+	if err := wav.WriteFile(path, speech); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("• Mixed music bed %v under the speech\n", musicPath)
+}
+
+// playAudio writes the clip of an <audio> element to destinationFile, it
+// returns false when the audio cannot be played and the fallback content is
+// to be synthesized instead.
+func playAudio(ctx context.Context, resolver *splice.Resolver, audio *ssmltext.Audio, destinationFile string) bool {
+	path, err := resolver.Resolve(audio.Src)
+	if err == nil {
+		err = splice.Clip(ctx, path, destinationFile, audio.ClipBegin, audio.ClipEnd)
+	}
+	if err != nil {
+		logging.Warningf("Reading the fallback '%s' instead of playing %s. Error: %s", audio.Fallback, audio.Src, err)
+		fmt.Printf("• Reading the fallback instead of playing %v: %v\n", audio.Src, err)
+		return false
+	}
+	logging.Infof("Audio %s written to file: %v", audio.Src, destinationFile)
+	return true
+}
+
+// newMarkClient returns a client for the REST API at -tts-endpoint or at
+// the default endpoint.
+func newMarkClient(ctx context.Context) *tts.Client {
+	var opts []option.ClientOption
+	if len(*ttsEndpoint) > 0 {
+		opts = append(opts, option.WithEndpoint(*ttsEndpoint))
+		if strings.HasPrefix(*ttsEndpoint, "http://") {
+			opts = append(opts, option.WithoutAuthentication())
+		}
+	}
+	client, err := tts.NewClient(ctx, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return client
+}
+
+// synthesizeWithMarks synthesizes ssml like SynthesizeSsmlToFile and returns
+// the times at which the marks in it are reached, by mark name.
+func synthesizeWithMarks(client *tts.Client, ctx context.Context, ssml, voice, destinationFile string) map[string]time.Duration {
+	if len(voice) == 0 {
+		voice = *defaultVoice
+	}
+	enc, _ := audioEncoding()
+	resp, err := client.Synthesize(ctx, &tts.Request{
+		Ssml:          ssml,
+		LanguageCode:  languageCodeOfVoice(voice),
+		Voice:         voice,
+		AudioEncoding: enc.String(),
+		Pitch:         -6.00,
+		SpeakingRate:  1.00,
+		Timepoints:    true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ioutil.WriteFile(destinationFile, resp.AudioContent, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.Infof("Audio content written to file: %v", destinationFile)
+	timepoints := make(map[string]time.Duration)
+	for _, tp := range resp.Timepoints {
+		timepoints[tp.MarkName] = tp.Time
+	}
+	return timepoints
+}
+
+// audioEncoding returns the encoding to request from the text-to-speech API
+// and the extension of the files with that encoding.
+func audioEncoding() (texttospeechpb.AudioEncoding, string) {
+	if len(renditions) > 0 {
+		return texttospeechpb.AudioEncoding_LINEAR16, ".wav"
+	}
+	switch *encoding {
+	case "mp3":
+		return texttospeechpb.AudioEncoding_MP3, ".mp3"
+	case "linear16":
+		return texttospeechpb.AudioEncoding_LINEAR16, ".wav"
+	}
+	log.Fatalf("Unknown encoding '%s', expected mp3 or linear16", *encoding)
+	return texttospeechpb.AudioEncoding_AUDIO_ENCODING_UNSPECIFIED, ""
+}
+
+// episodeMetadata is the metadata of an episode as read from the -metadata
+// file.
+type episodeMetadata struct {
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+	Album   string `json:"album"`
+	Track   string `json:"track"`
+	Date    string `json:"date"`
+	Genre   string `json:"genre"`
+	Comment string `json:"comment"`
+	URL     string `json:"url"`
+	Cover   string `json:"cover"`
+}
+
+// episodeTag returns the ID3 tag for the episode, with the metadata from the
+// -metadata file overridden by the tag flags, and the transcript as lyrics.
+func episodeTag(cues []transcript.Cue) *id3.Tag {
+	var m episodeMetadata
+	if len(*metadataFile) > 0 {
+		content, err := ioutil.ReadFile(*metadataFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(content, &m); err != nil {
+			log.Fatalf("Failed to parse metadata file %s. Error: %s", *metadataFile, err)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "title":
+			m.Title = value
+		case "artist":
+			m.Artist = value
+		case "album":
+			m.Album = value
+		case "track":
+			m.Track = value
+		case "date":
+			m.Date = value
+		case "genre":
+			m.Genre = value
+		case "comment":
+			m.Comment = value
+		case "url":
+			m.URL = value
+		case "cover":
+			m.Cover = value
+		}
+	})
+	if len(m.Title) == 0 {
+		m.Title = filepath.Base(*output)
+	}
+	if len(m.Genre) == 0 {
+		m.Genre = *genre
+	}
+	var lyrics []string
+	for _, c := range cues {
+		lyrics = append(lyrics, c.Text)
+	}
+	tag := &id3.Tag{
+		Version: byte(*id3Version),
+		Title:   m.Title,
+		Artist:  m.Artist,
+		Album:   m.Album,
+		Track:   m.Track,
+		Date:    m.Date,
+		Genre:   m.Genre,
+		Comment: m.Comment,
+		URL:     m.URL,
+		Lyrics:  strings.Join(lyrics, "\n\n"),
+	}
+	if len(m.Cover) > 0 {
+		var err error
+		if tag.Cover, err = id3.ReadCover(m.Cover); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return tag
+}
+
+// reservedTagSlack is the room that the reserved tag leaves beyond the size
+// of the episode tag.
+const reservedTagSlack = 256
+
+// reservedTag returns an empty ID3v2 tag with room for the tag of the
+// episode, so that the tag is written in place after the merge. The size is
+// known up front, only the fixed-width times in the tag depend on the merge.
+func reservedTag(timeline []ssmltext.Chunk, chapters []ssmltext.Chapter, chunkInputs []int) []byte {
+	result := &audiostats.Result{Inputs: make([]audiostats.Input, len(timeline))}
+	tag := episodeTag(transcript.FromChunks(timeline, make([]time.Duration, len(timeline)), make([]time.Duration, len(timeline))))
+	tag.Chapters = episodeChapters(chapters, chunkInputs, result)
+	encoded, err := tag.Bytes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The reserved tag is all padding after its 10 byte header.
+	reserved, err := (&id3.Tag{Version: tag.Version, Padding: len(encoded) - 10 + reservedTagSlack}).Bytes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return reserved
+}
+
+// episodeChapters returns the chapters of the document with the measured
+// offsets of the chunks they start with. chunkInputs holds the index of every
+// chunk in the merged inputs. The first chapter starts at the start of the
+// audio, an intro belongs to it.
+func episodeChapters(chapters []ssmltext.Chapter, chunkInputs []int, result *audiostats.Result) []id3.Chapter {
+	start := func(c ssmltext.Chapter) time.Duration {
+		return result.Inputs[chunkInputs[c.Chunk]].Start
+	}
+	var episode []id3.Chapter
+	for i, c := range chapters {
+		if c.Chunk >= len(chunkInputs) {
+			break
+		}
+		end := result.Duration
+		if i+1 < len(chapters) && chapters[i+1].Chunk < len(chunkInputs) {
+			end = start(chapters[i+1])
+		}
+		title := c.Title
+		if len(title) == 0 {
+			title = "Introduction"
+		}
+		chapter := id3.Chapter{Title: title, URL: c.URL, Start: start(c), End: end}
+		if i == 0 {
+			chapter.Start = 0
+		}
+		episode = append(episode, chapter)
+	}
+	return episode
+}
+
+// writeTranscripts writes the cues as WebVTT and SRT files next to the audio.
+func writeTranscripts(basename string, cues []transcript.Cue) {
+	for _, format := range []struct {
+		extension string
+		write     func(io.Writer, []transcript.Cue) error
+	}{
+		{".vtt", transcript.WriteVTT},
+		{".srt", transcript.WriteSRT},
+	} {
+		if err := transcript.WriteFile(basename+format.extension, cues, format.write); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Transcript written to file: %v\n", basename+format.extension)
+	}
+}
+
+// SynthesizeSsmlToFile synthesizes ssml with the named voice, or the default
+// voice when voice is empty.
+func SynthesizeSsmlToFile(client *texttospeech.Client, ctx context.Context, ssml, voice, destinationFile string) {
+	if len(voice) == 0 {
+		voice = *defaultVoice
+	}
+	enc, _ := audioEncoding()
 	// Perform the text-to-speech request on the text input with the selected
 	// voice parameters and audio file type.
 	req := texttospeechpb.SynthesizeSpeechRequest{
@@ -58,20 +824,16 @@ func SynthesizeSsmlToFile(client *texttospeech.Client, ctx context.Context, ssml
 		// Input: &texttospeechpb.SynthesisInput{
 		// 	InputSource: &texttospeechpb.SynthesisInput_Text{Text: string(content)},
 
-		// Build the voice request, select the language code ("en-US") and the SSML
-		// voice gender ("neutral").
+		// Build the voice request, the language code ("en-US") is the start of
+		// the voice name.
 		Voice: &texttospeechpb.VoiceSelectionParams{
-			LanguageCode: "en-US",
-			// Wavenet male voice:         "en-US-Wavenet-D",
-			// Wavenet female voice: en-US-Wavenet-C
-			// Standard voice: en-US-Standard-B
-			Name: "en-US-Wavenet-D",
+			LanguageCode: languageCodeOfVoice(voice),
+			Name:         voice,
 		},
 		AudioConfig: &texttospeechpb.AudioConfig{
-			//AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-			Pitch: -6.00,
-			SpeakingRate: 1.00,
-			AudioEncoding:  texttospeechpb.AudioEncoding_LINEAR16,
+			Pitch:         -6.00,
+			SpeakingRate:  1.00,
+			AudioEncoding: enc,
 		},
 	}
 
@@ -84,5 +846,29 @@ func SynthesizeSsmlToFile(client *texttospeech.Client, ctx context.Context, ssml
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Audio content written to file: %v\n", destinationFile)
+	logging.Infof("Audio content written to file: %v", destinationFile)
+}
+
+// languageCodeOfVoice returns the language code that the name of a Google
+// voice starts with, like "en-US" for "en-US-Wavenet-D".
+func languageCodeOfVoice(voice string) string {
+	parts := strings.SplitN(voice, "-", 3)
+	if len(parts) < 2 {
+		return voice
+	}
+	return parts[0] + "-" + parts[1]
+}
+
+// writeLinks writes the collected links with their position to a json file,
+// to be used for the show notes.
+func writeLinks(destinationFile string, links []ssmltext.Link) {
+	content, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ioutil.WriteFile(destinationFile, content, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d links written to file: %v\n", len(links), destinationFile)
 }
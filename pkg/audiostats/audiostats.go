@@ -0,0 +1,117 @@
+// Package audiostats describes the audio written by the mergers, so that
+// feeds, chapters and transcripts can use the measured numbers instead of
+// running external tools on the output.
+package audiostats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result describes a merged audio file.
+type Result struct {
+	// Duration is the total playing time.
+	Duration time.Duration
+	// Inputs describes every input file, in the order they were merged.
+	Inputs []Input
+	// Frames is the number of MP3 frames, or of PCM sample frames, written.
+	Frames int
+	// BitRates counts the frames written per bitrate in bits per second.
+	BitRates map[int]int
+	// Bytes is the size of the output file.
+	Bytes int64
+}
+
+// Input describes the part of the merged audio that comes from one input
+// file.
+type Input struct {
+	Path string
+	// Start is the offset of the input in the merged audio.
+	Start    time.Duration
+	Duration time.Duration
+	Frames   int
+	Bytes    int64
+}
+
+// Add appends an input to the result, starting where the audio merged so
+// far ends.
+func (r *Result) Add(in Input) {
+	in.Start = r.Duration
+	r.Inputs = append(r.Inputs, in)
+	r.Duration += in.Duration
+	r.Frames += in.Frames
+}
+
+// AddAt appends an input that starts at in.Start, which can overlap the
+// audio merged so far.
+func (r *Result) AddAt(in Input) {
+	r.Inputs = append(r.Inputs, in)
+	if end := in.Start + in.Duration; end > r.Duration {
+		r.Duration = end
+	}
+	r.Frames += in.Frames
+}
+
+// AddBitRate counts frames with the given bitrate.
+func (r *Result) AddBitRate(bitRate, frames int) {
+	if r.BitRates == nil {
+		r.BitRates = make(map[int]int)
+	}
+	r.BitRates[bitRate] += frames
+}
+
+// Starts returns the offset of every input in the merged audio.
+func (r *Result) Starts() []time.Duration {
+	starts := make([]time.Duration, len(r.Inputs))
+	for i, in := range r.Inputs {
+		starts[i] = in.Start
+	}
+	return starts
+}
+
+// Durations returns the duration of every input.
+func (r *Result) Durations() []time.Duration {
+	durations := make([]time.Duration, len(r.Inputs))
+	for i, in := range r.Inputs {
+		durations[i] = in.Duration
+	}
+	return durations
+}
+
+// AverageBitRate returns the bitrate in bits per second averaged over the
+// frames, weighted by frame count.
+func (r *Result) AverageBitRate() int {
+	var total, frames int
+	for bitRate, n := range r.BitRates {
+		total += bitRate * n
+		frames += n
+	}
+	if frames == 0 {
+		return 0
+	}
+	return total / frames
+}
+
+// IsVBR reports whether the frames have more than one bitrate.
+func (r *Result) IsVBR() bool {
+	return len(r.BitRates) > 1
+}
+
+func (r *Result) String() string {
+	var profile []string
+	var bitRates []int
+	for bitRate := range r.BitRates {
+		bitRates = append(bitRates, bitRate)
+	}
+	sort.Ints(bitRates)
+	for _, bitRate := range bitRates {
+		profile = append(profile, fmt.Sprintf("%d kbps: %d", bitRate/1000, r.BitRates[bitRate]))
+	}
+	s := fmt.Sprintf("%d files, %s, %d frames, %d bytes", len(r.Inputs), r.Duration.Round(time.Millisecond), r.Frames, r.Bytes)
+	if len(profile) > 0 {
+		s += " (" + strings.Join(profile, ", ") + ")"
+	}
+	return s
+}
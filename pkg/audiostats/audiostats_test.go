@@ -0,0 +1,26 @@
+package audiostats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResult(t *testing.T) {
+	var r Result
+	r.Add(Input{Path: "0.mp3", Duration: 2 * time.Second, Frames: 77, Bytes: 100})
+	r.Add(Input{Path: "1.mp3", Duration: time.Second, Frames: 38, Bytes: 50})
+	r.AddBitRate(32000, 100)
+	r.AddBitRate(64000, 15)
+	r.Bytes = 150
+
+	assert.Equal(t, 3*time.Second, r.Duration)
+	assert.Equal(t, 115, r.Frames)
+	assert.Equal(t, 2*time.Second, r.Inputs[1].Start)
+	assert.Equal(t, []time.Duration{2 * time.Second, time.Second}, r.Durations())
+	assert.Equal(t, []time.Duration{0, 2 * time.Second}, r.Starts())
+	assert.True(t, r.IsVBR())
+	assert.Equal(t, 36173, r.AverageBitRate())
+	assert.Equal(t, "2 files, 3s, 115 frames, 150 bytes (32 kbps: 100, 64 kbps: 15)", r.String())
+}
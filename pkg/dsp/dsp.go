@@ -0,0 +1,180 @@
+// Package dsp processes 16-bit PCM audio: gain ramps, mixing and ducking a
+// music bed under speech.
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// Span is a time span in the audio.
+type Span struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Point sets the gain at a time, between points the gain changes linearly.
+type Point struct {
+	At   time.Duration
+	Gain float64
+}
+
+// Envelope is a gain curve of points ordered by time. Before the first and
+// after the last point the gain of that point holds, an empty envelope has
+// gain 1.
+type Envelope []Point
+
+// At returns the gain at t.
+func (e Envelope) At(t time.Duration) float64 {
+	if len(e) == 0 {
+		return 1
+	}
+	i := sort.Search(len(e), func(i int) bool { return e[i].At > t })
+	if i == 0 {
+		return e[0].Gain
+	}
+	if i == len(e) {
+		return e[len(e)-1].Gain
+	}
+	p, q := e[i-1], e[i]
+	return p.Gain + (q.Gain-p.Gain)*float64(t-p.At)/float64(q.At-p.At)
+}
+
+// DB returns the linear gain of a level in decibels.
+func DB(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// Ducking describes how a music bed plays under speech.
+type Ducking struct {
+	// Gain is the gain of the music without speech, DuckGain during speech.
+	Gain     float64
+	DuckGain float64
+	// Attack is the time in which the music is ducked before speech starts
+	// and Release the time in which it comes back up after speech ends.
+	Attack  time.Duration
+	Release time.Duration
+	// FadeIn and FadeOut are the fades at the start and end of the music.
+	FadeIn  time.Duration
+	FadeOut time.Duration
+}
+
+// Envelope returns the gain curve of the music for audio of the given
+// duration with speech in the given spans. Spans closer together than the
+// attack and release stay ducked in between.
+func (d Ducking) Envelope(duration time.Duration, speech []Span) Envelope {
+	var ducked []Span
+	for _, s := range speech {
+		s.Start -= d.Attack
+		s.End += d.Release
+		if n := len(ducked); n > 0 && s.Start <= ducked[n-1].End {
+			if s.End > ducked[n-1].End {
+				ducked[n-1].End = s.End
+			}
+			continue
+		}
+		ducked = append(ducked, s)
+	}
+	var e Envelope
+	for _, s := range ducked {
+		e = append(e,
+			Point{At: s.Start, Gain: d.Gain},
+			Point{At: s.Start + d.Attack, Gain: d.DuckGain},
+			Point{At: s.End - d.Release, Gain: d.DuckGain},
+			Point{At: s.End, Gain: d.Gain})
+	}
+	if len(e) == 0 {
+		e = Envelope{{At: 0, Gain: d.Gain}}
+	}
+	return e.fade(duration, d.FadeIn, d.FadeOut)
+}
+
+// fade multiplies the envelope with a fade in from 0 and a fade out to the
+// end of the audio.
+func (e Envelope) fade(duration, fadeIn, fadeOut time.Duration) Envelope {
+	points := append(Envelope{}, e...)
+	for _, t := range []time.Duration{0, fadeIn, duration - fadeOut, duration} {
+		points = append(points, Point{At: t, Gain: e.At(t)})
+	}
+	// Points at the same time make a step, their order is kept.
+	sort.SliceStable(points, func(i, j int) bool { return points[i].At < points[j].At })
+	var faded Envelope
+	for _, p := range points {
+		if p.At < 0 || p.At > duration {
+			continue
+		}
+		if fadeIn > 0 && p.At < fadeIn {
+			p.Gain *= float64(p.At) / float64(fadeIn)
+		}
+		if fadeOut > 0 && p.At > duration-fadeOut {
+			p.Gain *= float64(duration-p.At) / float64(fadeOut)
+		}
+		faded = append(faded, p)
+	}
+	return faded
+}
+
+// ApplyGain multiplies the samples of audio with the envelope.
+func ApplyGain(audio *wav.Audio, e Envelope) {
+	channels := audio.Format.Channels
+	for frame := 0; frame < audio.Frames(); frame++ {
+		gain := e.At(wav.FramesDuration(frame, audio.Format.SampleRate))
+		for c := 0; c < channels; c++ {
+			i := frame*channels + c
+			audio.Samples[i] = clip(float64(audio.Samples[i]) * gain)
+		}
+	}
+}
+
+// Loop returns the samples of audio repeated to the given number of frames.
+func Loop(audio *wav.Audio, frames int) *wav.Audio {
+	looped := &wav.Audio{Format: audio.Format, Samples: make([]int16, frames*audio.Format.Channels)}
+	if len(audio.Samples) == 0 {
+		return looped
+	}
+	for i := range looped.Samples {
+		looped.Samples[i] = audio.Samples[i%len(audio.Samples)]
+	}
+	return looped
+}
+
+// Mix adds the samples of other, multiplied with its envelope, to audio.
+// other must have the format of audio, where it is shorter the rest of audio
+// is left as it is.
+func Mix(audio, other *wav.Audio, e Envelope) error {
+	if audio.Format != other.Format {
+		return fmt.Errorf("Cannot mix audio with format %s into audio with format %s", other.Format, audio.Format)
+	}
+	channels := audio.Format.Channels
+	for frame := 0; frame < audio.Frames() && frame < other.Frames(); frame++ {
+		gain := e.At(wav.FramesDuration(frame, audio.Format.SampleRate))
+		for c := 0; c < channels; c++ {
+			i := frame*channels + c
+			audio.Samples[i] = clip(float64(audio.Samples[i]) + float64(other.Samples[i])*gain)
+		}
+	}
+	return nil
+}
+
+// MixBed mixes a music bed, looped to the length of the speech, under the
+// speech with ducking during the speech spans.
+func MixBed(speech, bed *wav.Audio, spans []Span, d Ducking) error {
+	looped := Loop(bed, speech.Frames())
+	return Mix(speech, looped, d.Envelope(speech.Duration(), spans))
+}
+
+// clip rounds a sample value and limits it to the 16-bit range.
+func clip(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
@@ -0,0 +1,50 @@
+package dsp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	e := Envelope{{At: time.Second, Gain: 1}, {At: 3 * time.Second, Gain: 0}}
+	assert.Equal(t, 1.0, e.At(0))
+	assert.Equal(t, 0.5, e.At(2*time.Second))
+	assert.Equal(t, 0.0, e.At(5*time.Second))
+	assert.Equal(t, 1.0, Envelope{}.At(time.Second))
+	assert.InDelta(t, 0.1, DB(-20), 1e-9)
+}
+
+func TestDucking(t *testing.T) {
+	d := Ducking{Gain: 0.5, DuckGain: 0.1, Attack: time.Second, Release: time.Second, FadeIn: time.Second, FadeOut: 2 * time.Second}
+	e := d.Envelope(20*time.Second, []Span{{Start: 4 * time.Second, End: 6 * time.Second}, {Start: 7 * time.Second, End: 8 * time.Second}})
+	assert.Equal(t, 0.0, e.At(0))
+	assert.Equal(t, 0.5, e.At(time.Second))
+	assert.Equal(t, 0.5, e.At(3*time.Second))
+	assert.InDelta(t, 0.1, e.At(4*time.Second), 1e-9)
+	// The gap between the spans is shorter than attack and release.
+	assert.InDelta(t, 0.1, e.At(6500*time.Millisecond), 1e-9)
+	assert.InDelta(t, 0.3, e.At(8500*time.Millisecond), 1e-9)
+	assert.Equal(t, 0.5, e.At(15*time.Second))
+	assert.InDelta(t, 0.25, e.At(19*time.Second), 1e-9)
+	assert.Equal(t, 0.0, e.At(20*time.Second))
+}
+
+func TestMixBed(t *testing.T) {
+	format := wav.Format{SampleRate: 10, Channels: 1}
+	speech := &wav.Audio{Format: format, Samples: []int16{100, 100, 100, 100, 32700, 32000, 0, 0}}
+	bed := &wav.Audio{Format: format, Samples: []int16{1000, -1000}}
+	err := MixBed(speech, bed, []Span{{Start: 400 * time.Millisecond, End: 600 * time.Millisecond}}, Ducking{Gain: 1, DuckGain: 0.5})
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1100, -900, 1100, -900, 32767, 31500, 1000, -1000}, speech.Samples)
+
+	assert.NotNil(t, Mix(speech, &wav.Audio{Format: wav.Format{SampleRate: 20, Channels: 1}}, nil))
+}
+
+func TestApplyGain(t *testing.T) {
+	audio := &wav.Audio{Format: wav.Format{SampleRate: 2, Channels: 2}, Samples: []int16{100, 100, 100, 100, 100, 100}}
+	ApplyGain(audio, Envelope{{At: 0, Gain: 0}, {At: time.Second, Gain: 1}})
+	assert.Equal(t, []int16{0, 0, 50, 50, 100, 100}, audio.Samples)
+}
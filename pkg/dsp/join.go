@@ -0,0 +1,72 @@
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// Crossfade returns a joiner that overlaps the end of the merged audio and
+// the start of the next audio for the given duration, with an equal power
+// fade, so that the join has no click.
+func Crossfade(d time.Duration) wav.Joiner {
+	return func(merged, next *wav.Audio) int {
+		channels := merged.Format.Channels
+		n := int(int64(d) * int64(merged.Format.SampleRate) / int64(time.Second))
+		if f := merged.Frames(); f < n {
+			n = f
+		}
+		if f := next.Frames(); f < n {
+			n = f
+		}
+		start := merged.Frames() - n
+		for frame := 0; frame < n; frame++ {
+			x := (float64(frame) + 0.5) / float64(n) * math.Pi / 2
+			out, in := math.Cos(x), math.Sin(x)
+			for c := 0; c < channels; c++ {
+				i := (start+frame)*channels + c
+				merged.Samples[i] = clip(float64(merged.Samples[i])*out + float64(next.Samples[frame*channels+c])*in)
+			}
+		}
+		merged.Samples = append(merged.Samples, next.Samples[n*channels:]...)
+		return start
+	}
+}
+
+// ZeroCrossing returns a joiner that cuts the end of the merged audio and
+// the start of the next audio at a zero crossing, searching at most the
+// given duration, so that the waveforms meet at zero.
+func ZeroCrossing(d time.Duration) wav.Joiner {
+	return func(merged, next *wav.Audio) int {
+		channels := merged.Format.Channels
+		window := int(int64(d) * int64(merged.Format.SampleRate) / int64(time.Second))
+		// level is the sum of the channels of a frame.
+		level := func(a *wav.Audio, frame int) int {
+			var sum int
+			for c := 0; c < channels; c++ {
+				sum += int(a.Samples[frame*channels+c])
+			}
+			return sum
+		}
+		crosses := func(a *wav.Audio, frame int) bool {
+			return level(a, frame) == 0 || (level(a, frame-1) < 0) != (level(a, frame) < 0)
+		}
+		end := merged.Frames()
+		for f := end - 1; f > 0 && f >= end-window; f-- {
+			if crosses(merged, f) {
+				end = f
+				break
+			}
+		}
+		begin := 0
+		for f := 1; f < next.Frames() && f <= window; f++ {
+			if crosses(next, f) {
+				begin = f
+				break
+			}
+		}
+		merged.Samples = append(merged.Samples[:end*channels], next.Samples[begin*channels:]...)
+		return end
+	}
+}
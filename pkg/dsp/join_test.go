@@ -0,0 +1,50 @@
+package dsp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossfade(t *testing.T) {
+	format := wav.Format{SampleRate: 10, Channels: 1}
+	merged := &wav.Audio{Format: format, Samples: []int16{1000, 1000, 1000, 1000}}
+	next := &wav.Audio{Format: format, Samples: []int16{-1000, -1000, -1000}}
+	start := Crossfade(200*time.Millisecond)(merged, next)
+	assert.Equal(t, 2, start)
+	assert.Equal(t, []int16{1000, 1000, 541, -541, -1000}, merged.Samples)
+
+	// The overlap is at most the length of the audio.
+	merged = &wav.Audio{Format: format, Samples: []int16{5}}
+	assert.Equal(t, 0, Crossfade(time.Second)(merged, next))
+	assert.Equal(t, 3, len(merged.Samples))
+}
+
+func TestZeroCrossing(t *testing.T) {
+	format := wav.Format{SampleRate: 10, Channels: 1}
+	merged := &wav.Audio{Format: format, Samples: []int16{500, -200, 300, 400, 600}}
+	next := &wav.Audio{Format: format, Samples: []int16{700, 300, -100, -400}}
+	start := ZeroCrossing(300*time.Millisecond)(merged, next)
+	assert.Equal(t, 2, start)
+	assert.Equal(t, []int16{500, -200, -100, -400}, merged.Samples)
+}
+
+func TestMergeWithCrossfade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dsp")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	format := wav.Format{SampleRate: 10, Channels: 1}
+	first, second := filepath.Join(dir, "0.wav"), filepath.Join(dir, "1.wav")
+	assert.Nil(t, wav.WriteFile(first, &wav.Audio{Format: format, Samples: make([]int16, 10)}))
+	assert.Nil(t, wav.WriteFile(second, &wav.Audio{Format: format, Samples: make([]int16, 10)}))
+	result, err := wav.MergeWith(filepath.Join(dir, "out.wav"), []string{first, second}, Crossfade(100*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, 900*time.Millisecond, result.Inputs[1].Start)
+	assert.Equal(t, 1900*time.Millisecond, result.Duration)
+	assert.Equal(t, 19, result.Frames)
+}
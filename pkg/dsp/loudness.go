@@ -0,0 +1,168 @@
+package dsp
+
+import (
+	"math"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// biquad is a second order IIR filter in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the two filters of the K-weighting of ITU-R BS.1770,
+// a high shelf for the head and a high pass, for any sample rate.
+func kWeighting(sampleRate int) (*biquad, *biquad) {
+	fs := float64(sampleRate)
+
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// Loudness returns the integrated loudness of the audio in LUFS as defined
+// by ITU-R BS.1770-4 and EBU R128: the K-weighted power of 400ms blocks that
+// overlap by 75%, gated at -70 LUFS and at 10 LU below the ungated loudness.
+// Audio that is too short or silent has loudness -Inf.
+func Loudness(audio *wav.Audio) float64 {
+	channels := audio.Format.Channels
+	frames := audio.Frames()
+	if channels == 0 || audio.Format.SampleRate == 0 {
+		return math.Inf(-1)
+	}
+	// The squared K-weighted samples, summed over the channels, which all
+	// have weight 1 for mono and stereo.
+	power := make([]float64, frames)
+	for c := 0; c < channels; c++ {
+		shelf, highPass := kWeighting(audio.Format.SampleRate)
+		for i := 0; i < frames; i++ {
+			y := highPass.process(shelf.process(float64(audio.Samples[i*channels+c]) / 32768))
+			power[i] += y * y
+		}
+	}
+	block := audio.Format.SampleRate * 4 / 10
+	step := block / 4
+	var blocks []float64
+	for start := 0; step > 0 && start+block <= frames; start += step {
+		var sum float64
+		for _, p := range power[start : start+block] {
+			sum += p
+		}
+		blocks = append(blocks, sum/float64(block))
+	}
+	gated := func(threshold float64) float64 {
+		var sum float64
+		var n int
+		for _, z := range blocks {
+			if blockLoudness(z) > threshold {
+				sum += z
+				n++
+			}
+		}
+		if n == 0 {
+			return math.Inf(-1)
+		}
+		return blockLoudness(sum / float64(n))
+	}
+	relative := gated(-70)
+	if math.IsInf(relative, -1) {
+		return relative
+	}
+	return gated(relative - 10)
+}
+
+func blockLoudness(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+// truePeakTaps is the number of taps per phase of the interpolation filter.
+const truePeakTaps = 12
+
+// TruePeak returns the true peak of the audio in dBTP, the peak of the
+// signal oversampled four times as in ITU-R BS.1770-4 annex 2.
+func TruePeak(audio *wav.Audio) float64 {
+	channels := audio.Format.Channels
+	frames := audio.Frames()
+	// A windowed sinc filter for each of the four phases.
+	var phases [4][truePeakTaps]float64
+	for p := range phases {
+		for t := range phases[p] {
+			x := float64(t-truePeakTaps/2+1) - float64(p)/4
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/(truePeakTaps/2))
+			phases[p][t] = sinc(x) * window
+		}
+	}
+	var peak float64
+	for c := 0; c < channels; c++ {
+		sample := func(i int) float64 {
+			if i < 0 || i >= frames {
+				return 0
+			}
+			return float64(audio.Samples[i*channels+c]) / 32768
+		}
+		for i := 0; i < frames; i++ {
+			for p := range phases {
+				var y float64
+				for t, h := range phases[p] {
+					y += h * sample(i+truePeakTaps/2-1-t)
+				}
+				peak = math.Max(peak, math.Abs(y))
+			}
+		}
+	}
+	return 20 * math.Log10(peak)
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// Normalize applies the gain that brings the loudness of the audio to the
+// target in LUFS, lowered where needed to keep the true peak at or below the
+// ceiling in dBTP. It returns the gain applied in dB, silent audio is left as
+// it is.
+func Normalize(audio *wav.Audio, target, ceiling float64) float64 {
+	loudness := Loudness(audio)
+	if math.IsInf(loudness, -1) {
+		return 0
+	}
+	gain := target - loudness
+	if peak := TruePeak(audio); peak+gain > ceiling {
+		gain = ceiling - peak
+	}
+	ApplyGain(audio, Envelope{{Gain: DB(gain)}})
+	return gain
+}
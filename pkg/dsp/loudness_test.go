@@ -0,0 +1,45 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+// sine returns seconds of a 997 Hz sine with the given peak amplitude.
+func sine(format wav.Format, amplitude float64, seconds int) *wav.Audio {
+	audio := &wav.Audio{Format: format, Samples: make([]int16, seconds*format.SampleRate*format.Channels)}
+	for i := range audio.Samples {
+		frame := i / format.Channels
+		audio.Samples[i] = int16(amplitude * 32767 * math.Sin(2*math.Pi*997*float64(frame)/float64(format.SampleRate)))
+	}
+	return audio
+}
+
+func TestLoudness(t *testing.T) {
+	// A full scale 997 Hz sine in one channel is -3.01 LUFS.
+	assert.InDelta(t, -3.01, Loudness(sine(wav.Format{SampleRate: 48000, Channels: 1}, 1, 3)), 0.05)
+	assert.InDelta(t, -9.03, Loudness(sine(wav.Format{SampleRate: 24000, Channels: 1}, 0.5, 3)), 0.05)
+	// The same sine in both channels adds 3 dB.
+	assert.InDelta(t, -6.02, Loudness(sine(wav.Format{SampleRate: 44100, Channels: 2}, 0.5, 3)), 0.05)
+	assert.True(t, math.IsInf(Loudness(&wav.Audio{Format: wav.Format{SampleRate: 48000, Channels: 1}, Samples: make([]int16, 48000)}), -1))
+}
+
+func TestTruePeak(t *testing.T) {
+	assert.InDelta(t, -6.02, TruePeak(sine(wav.Format{SampleRate: 24000, Channels: 1}, 0.5, 1)), 0.2)
+}
+
+func TestNormalize(t *testing.T) {
+	audio := sine(wav.Format{SampleRate: 24000, Channels: 1}, 0.1, 3)
+	gain := Normalize(audio, -16, -1)
+	assert.InDelta(t, 7.0, gain, 0.1)
+	assert.InDelta(t, -16, Loudness(audio), 0.1)
+
+	// A sine at -16 LUFS peaks at about -13 dBTP, at -6 LUFS the ceiling
+	// limits the gain.
+	audio = sine(wav.Format{SampleRate: 24000, Channels: 1}, 0.1, 3)
+	Normalize(audio, -6, -6)
+	assert.InDelta(t, -6, TruePeak(audio), 0.2)
+}
@@ -0,0 +1,140 @@
+package dsp
+
+import (
+	"math"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// resampleZeros is the number of zero crossings of the interpolation filter
+// on each side.
+const resampleZeros = 16
+
+// maxResamplePhases limits the size of the filter table. Rates with a ratio
+// that needs more phases compute the filter for every output sample instead.
+const maxResamplePhases = 4096
+
+// Resample returns the audio at another sample rate, interpolated with a
+// windowed sinc filter that also removes the frequencies above the new
+// Nyquist frequency when downsampling. The filter is computed once for every
+// phase of the ratio between the rates, as up/down samples repeat the same
+// offsets between input and output samples.
+func Resample(audio *wav.Audio, sampleRate int) *wav.Audio {
+	from := audio.Format.SampleRate
+	if from == sampleRate || from == 0 || sampleRate == 0 {
+		return audio
+	}
+	g := gcd(from, sampleRate)
+	up, down := sampleRate/g, from/g
+	channels := audio.Format.Channels
+	frames := audio.Frames()
+	f := newResampleFilter(float64(sampleRate)/float64(from), up)
+	outFrames := frames * up / down
+	resampled := &wav.Audio{Format: wav.Format{SampleRate: sampleRate, Channels: channels}, Samples: make([]int16, outFrames*channels)}
+	for n := 0; n < outFrames; n++ {
+		// Output sample n falls at input sample base plus phase/up.
+		base, phase := n*down/up, n*down%up
+		taps := f.phase(phase)
+		first := base - f.reach
+		for c := 0; c < channels; c++ {
+			var sum float64
+			for k, tap := range taps {
+				if i := first + k; i >= 0 && i < frames {
+					sum += float64(audio.Samples[i*channels+c]) * tap
+				}
+			}
+			resampled.Samples[n*channels+c] = clip(sum)
+		}
+	}
+	return resampled
+}
+
+// resampleFilter holds the taps of the interpolation filter for every phase
+// of the resampling ratio.
+type resampleFilter struct {
+	cutoff, width float64
+	phases        int
+	// reach is the number of input samples before the base sample that the
+	// filter covers.
+	reach int
+	table [][]float64
+	taps  []float64
+}
+
+func newResampleFilter(ratio float64, phases int) *resampleFilter {
+	f := &resampleFilter{cutoff: math.Min(1, ratio), phases: phases}
+	f.width = float64(resampleZeros) / f.cutoff
+	f.reach = int(math.Ceil(f.width))
+	if phases > maxResamplePhases {
+		f.taps = make([]float64, 2*f.reach+1)
+		return f
+	}
+	f.table = make([][]float64, phases)
+	for p := range f.table {
+		f.table[p] = make([]float64, 2*f.reach+1)
+		f.compute(p, f.table[p])
+	}
+	return f
+}
+
+// phase returns the taps for an output sample at phase/phases after an input
+// sample.
+func (f *resampleFilter) phase(p int) []float64 {
+	if f.table != nil {
+		return f.table[p]
+	}
+	f.compute(p, f.taps)
+	return f.taps
+}
+
+func (f *resampleFilter) compute(p int, taps []float64) {
+	offset := float64(p) / float64(f.phases)
+	for k := range taps {
+		x := float64(k-f.reach) - offset
+		if math.Abs(x) > f.width {
+			taps[k] = 0
+			continue
+		}
+		window := 0.5 + 0.5*math.Cos(math.Pi*x/f.width)
+		taps[k] = f.cutoff * sinc(f.cutoff*x) * window
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Remix returns the audio with another number of channels. Mono is copied
+// to every channel, other audio is mixed down to mono first.
+func Remix(audio *wav.Audio, channels int) *wav.Audio {
+	from := audio.Format.Channels
+	if from == channels || from == 0 || channels == 0 {
+		return audio
+	}
+	frames := audio.Frames()
+	remixed := &wav.Audio{Format: wav.Format{SampleRate: audio.Format.SampleRate, Channels: channels}, Samples: make([]int16, frames*channels)}
+	for f := 0; f < frames; f++ {
+		var sum int
+		for c := 0; c < from; c++ {
+			sum += int(audio.Samples[f*from+c])
+		}
+		mono := clip(float64(sum) / float64(from))
+		for c := 0; c < channels; c++ {
+			remixed.Samples[f*channels+c] = mono
+		}
+	}
+	return remixed
+}
+
+// Convert returns the audio in another format. Zero fields of the format
+// keep the value of the audio.
+func Convert(audio *wav.Audio, format wav.Format) *wav.Audio {
+	if format.Channels < audio.Format.Channels {
+		// Mixing down first resamples fewer channels.
+		return Resample(Remix(audio, format.Channels), format.SampleRate)
+	}
+	return Remix(Resample(audio, format.SampleRate), format.Channels)
+}
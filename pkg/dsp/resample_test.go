@@ -0,0 +1,55 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResample(t *testing.T) {
+	in := sine(wav.Format{SampleRate: 24000, Channels: 1}, 0.5, 1)
+	out := Resample(in, 48000)
+	assert.Equal(t, wav.Format{SampleRate: 48000, Channels: 1}, out.Format)
+	assert.Equal(t, 48000, out.Frames())
+	// The resampled sine matches a sine generated at the new rate, away from
+	// the edges.
+	expected := sine(wav.Format{SampleRate: 48000, Channels: 1}, 0.5, 1)
+	for i := 1000; i < 47000; i += 997 {
+		assert.InDelta(t, float64(expected.Samples[i]), float64(out.Samples[i]), 100, "sample %d", i)
+	}
+
+	down := Resample(sine(wav.Format{SampleRate: 44100, Channels: 2}, 0.5, 1), 24000)
+	assert.Equal(t, 24000, down.Frames())
+	assert.InDelta(t, -6.02, Loudness(down), 0.1)
+
+	// Rates with too many phases for a filter table give the same result.
+	odd := Resample(in, 24001)
+	assert.Equal(t, 24001, odd.Frames())
+	assert.InDelta(t, Loudness(in), Loudness(odd), 0.1)
+
+	// A tone above the new Nyquist frequency is filtered out.
+	high := &wav.Audio{Format: wav.Format{SampleRate: 48000, Channels: 1}, Samples: make([]int16, 48000)}
+	for i := range high.Samples {
+		high.Samples[i] = int16(16000 * math.Sin(2*math.Pi*18000*float64(i)/48000))
+	}
+	filtered := Resample(high, 24000)
+	var peak int16
+	for _, s := range filtered.Samples[1000:23000] {
+		if s > peak {
+			peak = s
+		}
+	}
+	assert.True(t, peak < 500, "peak %d", peak)
+}
+
+func TestRemix(t *testing.T) {
+	stereo := &wav.Audio{Format: wav.Format{SampleRate: 10, Channels: 2}, Samples: []int16{100, 300, -50, 50}}
+	mono := Remix(stereo, 1)
+	assert.Equal(t, []int16{200, 0}, mono.Samples)
+	assert.Equal(t, []int16{200, 200, 0, 0}, Remix(mono, 2).Samples)
+
+	converted := Convert(stereo, wav.Format{Channels: 1})
+	assert.Equal(t, wav.Format{SampleRate: 10, Channels: 1}, converted.Format)
+}
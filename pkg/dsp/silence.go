@@ -0,0 +1,58 @@
+package dsp
+
+import (
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// TrimSilence returns the audio without its leading and trailing silence,
+// which is every frame where no channel exceeds the threshold, a linear
+// amplitude like DB(-50). margin keeps some of the silence on both sides so
+// that soft onsets and decays are not cut. The samples are shared with
+// audio. It also returns the duration of the leading silence that was cut,
+// by which times measured in audio move forward.
+func TrimSilence(audio *wav.Audio, threshold float64, margin time.Duration) (*wav.Audio, time.Duration) {
+	channels := audio.Format.Channels
+	frames := audio.Frames()
+	limit := threshold * 32768
+	loud := func(frame int) bool {
+		for c := 0; c < channels; c++ {
+			s := float64(audio.Samples[frame*channels+c])
+			if s > limit || -s > limit {
+				return true
+			}
+		}
+		return false
+	}
+	first := 0
+	for first < frames && !loud(first) {
+		first++
+	}
+	last := frames
+	for last > first && !loud(last-1) {
+		last--
+	}
+	if first == last {
+		return &wav.Audio{Format: audio.Format}, audio.Duration()
+	}
+	keep := int(int64(margin) * int64(audio.Format.SampleRate) / int64(time.Second))
+	first -= keep
+	if first < 0 {
+		first = 0
+	}
+	last += keep
+	if last > frames {
+		last = frames
+	}
+	lead := time.Duration(int64(first) * int64(time.Second) / int64(audio.Format.SampleRate))
+	return &wav.Audio{Format: audio.Format, Samples: audio.Samples[first*channels : last*channels]}, lead
+}
+
+// Pad returns the audio followed by silence of the given duration.
+func Pad(audio *wav.Audio, d time.Duration) *wav.Audio {
+	frames := int(int64(d) * int64(audio.Format.SampleRate) / int64(time.Second))
+	samples := make([]int16, len(audio.Samples), len(audio.Samples)+frames*audio.Format.Channels)
+	copy(samples, audio.Samples)
+	return &wav.Audio{Format: audio.Format, Samples: append(samples, make([]int16, frames*audio.Format.Channels)...)}
+}
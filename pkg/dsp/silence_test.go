@@ -0,0 +1,32 @@
+package dsp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimSilence(t *testing.T) {
+	format := wav.Format{SampleRate: 10, Channels: 2}
+	audio := &wav.Audio{Format: format, Samples: []int16{0, 0, 3, -3, 0, 0, 0, 500, 0, 0, 0, 0, -900, 0, 4, 0, 0, 0, 0, 0}}
+	trimmed, lead := TrimSilence(audio, DB(-40), 0)
+	assert.Equal(t, []int16{0, 500, 0, 0, 0, 0, -900, 0}, trimmed.Samples)
+	assert.Equal(t, 300*time.Millisecond, lead)
+
+	trimmed, lead = TrimSilence(audio, DB(-40), 100*time.Millisecond)
+	assert.Equal(t, []int16{0, 0, 0, 500, 0, 0, 0, 0, -900, 0, 4, 0}, trimmed.Samples)
+	assert.Equal(t, 200*time.Millisecond, lead)
+
+	trimmed, lead = TrimSilence(&wav.Audio{Format: format, Samples: make([]int16, 8)}, DB(-40), 0)
+	assert.Equal(t, 0, len(trimmed.Samples))
+	assert.Equal(t, 400*time.Millisecond, lead)
+}
+
+func TestPad(t *testing.T) {
+	audio := &wav.Audio{Format: wav.Format{SampleRate: 10, Channels: 1}, Samples: []int16{1, 2}}
+	padded := Pad(audio, 300*time.Millisecond)
+	assert.Equal(t, []int16{1, 2, 0, 0, 0}, padded.Samples)
+	assert.Equal(t, []int16{1, 2}, audio.Samples)
+}
@@ -0,0 +1,178 @@
+// Package encoder encodes the processed PCM audio to the output format, once
+// at the end of the pipeline.
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// Encoder encodes a WAV file to another file.
+type Encoder interface {
+	// Extension returns the extension of the encoded files, like ".mp3".
+	Extension() string
+	Encode(ctx context.Context, wavPath, outPath string) error
+}
+
+// WAV writes 16-bit PCM WAV files without external tools.
+type WAV struct{}
+
+// Extension returns ".wav".
+func (WAV) Extension() string {
+	return ".wav"
+}
+
+// Encode rewrites the WAV file to outPath, which can be wavPath itself.
+func (WAV) Encode(ctx context.Context, wavPath, outPath string) error {
+	if wavPath == outPath {
+		return nil
+	}
+	audio, err := wav.ReadFile(wavPath)
+	if err != nil {
+		return err
+	}
+	return wav.WriteFile(outPath, audio)
+}
+
+// codecs maps the formats that ffmpeg encodes to its encoder and the file
+// extension.
+var codecs = map[string][2]string{
+	"mp3":  {"libmp3lame", ".mp3"},
+	"aac":  {"aac", ".m4a"},
+	"opus": {"libopus", ".opus"},
+}
+
+// FFmpeg encodes with a locally installed ffmpeg.
+type FFmpeg struct {
+	// Binary is the ffmpeg command, empty means "ffmpeg".
+	Binary string
+	// Format is mp3, aac or opus.
+	Format string
+	// BitRate is in kbps, zero leaves the choice to ffmpeg.
+	BitRate int
+	// Channels and SampleRate convert the audio when they are not zero.
+	Channels   int
+	SampleRate int
+	// Metadata is written as tags, like title and artist.
+	Metadata map[string]string
+}
+
+// Extension returns the extension of the format.
+func (f *FFmpeg) Extension() string {
+	return codecs[f.Format][1]
+}
+
+func (f *FFmpeg) args(wavPath, outPath string) []string {
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-i", wavPath, "-vn", "-c:a", codecs[f.Format][0]}
+	if f.BitRate > 0 {
+		args = append(args, "-b:a", strconv.Itoa(f.BitRate)+"k")
+	}
+	if f.Channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(f.Channels))
+	}
+	if f.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(f.SampleRate))
+	}
+	var keys []string
+	for key := range f.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-metadata", key+"="+f.Metadata[key])
+	}
+	return append(args, outPath)
+}
+
+// Encode runs ffmpeg.
+func (f *FFmpeg) Encode(ctx context.Context, wavPath, outPath string) error {
+	if _, ok := codecs[f.Format]; !ok {
+		return fmt.Errorf("ffmpeg cannot encode to '%s', expected mp3, aac or opus", f.Format)
+	}
+	return run(ctx, binary(f.Binary, "ffmpeg"), f.args(wavPath, outPath))
+}
+
+// Lame encodes MP3 with a locally installed lame.
+type Lame struct {
+	// Binary is the lame command, empty means "lame".
+	Binary string
+	// BitRate is the constant bitrate in kbps, zero leaves the choice to
+	// lame.
+	BitRate int
+	// Mono downmixes to a single channel.
+	Mono bool
+}
+
+// Extension returns ".mp3".
+func (l *Lame) Extension() string {
+	return ".mp3"
+}
+
+func (l *Lame) args(wavPath, outPath string) []string {
+	args := []string{"--quiet"}
+	if l.BitRate > 0 {
+		args = append(args, "--cbr", "-b", strconv.Itoa(l.BitRate))
+	}
+	if l.Mono {
+		args = append(args, "-m", "m")
+	}
+	return append(args, wavPath, outPath)
+}
+
+// Encode runs lame.
+func (l *Lame) Encode(ctx context.Context, wavPath, outPath string) error {
+	return run(ctx, binary(l.Binary, "lame"), l.args(wavPath, outPath))
+}
+
+func binary(configured, name string) string {
+	if len(configured) > 0 {
+		return configured
+	}
+	return name
+}
+
+func run(ctx context.Context, name string, args []string) error {
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed. Error: %s %s", name, strings.Join(args, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// New returns the encoder for a format: wav, mp3, aac or opus. tool selects
+// ffmpeg or lame, for auto MP3 is encoded with ffmpeg when it is installed
+// and with lame otherwise.
+func New(format, tool string, bitRate int) (Encoder, error) {
+	if format == "wav" {
+		return WAV{}, nil
+	}
+	if _, ok := codecs[format]; !ok {
+		return nil, fmt.Errorf("Unknown format '%s', expected wav, mp3, aac or opus", format)
+	}
+	if tool == "auto" {
+		tool = "ffmpeg"
+		if _, err := exec.LookPath("ffmpeg"); err != nil && format == "mp3" {
+			tool = "lame"
+		}
+	}
+	switch tool {
+	case "ffmpeg":
+		return &FFmpeg{Format: format, BitRate: bitRate}, nil
+	case "lame":
+		if format != "mp3" {
+			return nil, fmt.Errorf("lame cannot encode to '%s'", format)
+		}
+		return &Lame{BitRate: bitRate}, nil
+	}
+	return nil, fmt.Errorf("Unknown encoder '%s', expected auto, ffmpeg or lame", tool)
+}
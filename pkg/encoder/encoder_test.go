@@ -0,0 +1,63 @@
+package encoder
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	e, err := New("wav", "auto", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, ".wav", e.Extension())
+
+	e, err = New("opus", "ffmpeg", 48)
+	assert.Nil(t, err)
+	assert.Equal(t, ".opus", e.Extension())
+
+	_, err = New("aac", "lame", 0)
+	assert.NotNil(t, err)
+	_, err = New("flac", "auto", 0)
+	assert.NotNil(t, err)
+}
+
+func TestArgs(t *testing.T) {
+	f := &FFmpeg{Format: "mp3", BitRate: 128, Channels: 2, SampleRate: 44100}
+	assert.Equal(t, "-y -hide_banner -loglevel error -i in.wav -vn -c:a libmp3lame -b:a 128k -ac 2 -ar 44100 out.mp3",
+		strings.Join(f.args("in.wav", "out.mp3"), " "))
+	f = &FFmpeg{Format: "opus", Metadata: map[string]string{"title": "Episode 1", "artist": "HN"}}
+	assert.Equal(t, "-y -hide_banner -loglevel error -i in.wav -vn -c:a libopus -metadata artist=HN -metadata title=Episode 1 out.opus",
+		strings.Join(f.args("in.wav", "out.opus"), " "))
+	l := &Lame{BitRate: 48, Mono: true}
+	assert.Equal(t, "--quiet --cbr -b 48 -m m in.wav out.mp3", strings.Join(l.args("in.wav", "out.mp3"), " "))
+}
+
+func TestEncodeRunsTheTool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encoder")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	// A fake ffmpeg that copies its input to its last argument.
+	fake := filepath.Join(dir, "ffmpeg")
+	assert.Nil(t, ioutil.WriteFile(fake, []byte("#!/bin/sh\nfor last; do :; done\ncp \"$6\" \"$last\"\n"), 0755))
+	in, out := filepath.Join(dir, "in.wav"), filepath.Join(dir, "out.mp3")
+	assert.Nil(t, wav.WriteFile(in, &wav.Audio{Format: wav.Format{SampleRate: 24000, Channels: 1}, Samples: []int16{1}}))
+
+	assert.Nil(t, (&FFmpeg{Binary: fake, Format: "mp3"}).Encode(context.Background(), in, out))
+	_, err = os.Stat(out)
+	assert.Nil(t, err)
+
+	err = (&FFmpeg{Binary: filepath.Join(dir, "missing"), Format: "mp3"}).Encode(context.Background(), in, out)
+	assert.NotNil(t, err)
+
+	copied := filepath.Join(dir, "copy.wav")
+	assert.Nil(t, WAV{}.Encode(context.Background(), in, copied))
+	audio, err := wav.ReadFile(copied)
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1}, audio.Samples)
+}
@@ -0,0 +1,121 @@
+package encoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rendition is one of the outputs of a job, encoded from the same audio.
+type Rendition struct {
+	// Name is added to the output file name, empty uses the output file name
+	// as it is.
+	Name       string
+	Format     string
+	BitRate    int
+	Channels   int
+	SampleRate int
+	// Title and Comment replace the title and comment of the episode in the
+	// tag of the rendition when they are set.
+	Title   string
+	Comment string
+}
+
+// ParseRendition parses a rendition written as the format followed by
+// comma separated options, like "mp3,bitrate=48,channels=1,name=mobile".
+// Without a name the rendition is named after its format and bitrate. The
+// title and comment options cannot contain a comma.
+func ParseRendition(s string) (Rendition, error) {
+	parts := strings.Split(s, ",")
+	r := Rendition{Format: strings.TrimSpace(parts[0])}
+	var named bool
+	for _, option := range parts[1:] {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return r, fmt.Errorf("Rendition option '%s' is not formatted as key=value", option)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var err error
+		switch key {
+		case "name":
+			r.Name, named = value, true
+		case "bitrate":
+			r.BitRate, err = strconv.Atoi(strings.TrimSuffix(value, "k"))
+		case "channels":
+			r.Channels, err = strconv.Atoi(value)
+		case "rate":
+			r.SampleRate, err = strconv.Atoi(value)
+		case "title":
+			r.Title = value
+		case "comment":
+			r.Comment = value
+		default:
+			return r, fmt.Errorf("Unknown rendition option '%s', expected name, bitrate, channels, rate, title or comment", key)
+		}
+		if err != nil {
+			return r, fmt.Errorf("Invalid value for rendition option %s. Error: %s", key, err)
+		}
+	}
+	if _, ok := codecs[r.Format]; !ok && r.Format != "wav" {
+		return r, fmt.Errorf("Unknown format '%s' in rendition %s, expected wav, mp3, aac or opus", r.Format, s)
+	}
+	if !named {
+		r.Name = r.Format
+		if r.BitRate > 0 {
+			r.Name += fmt.Sprintf("-%dk", r.BitRate)
+		}
+		if r.Channels == 1 {
+			r.Name += "-mono"
+		}
+	}
+	return r, nil
+}
+
+// Encoder returns the encoder for the rendition with the given tool, see
+// New.
+func (r Rendition) Encoder(tool string) (Encoder, error) {
+	enc, err := New(r.Format, tool, r.BitRate)
+	if err != nil {
+		return nil, err
+	}
+	switch e := enc.(type) {
+	case *FFmpeg:
+		e.Channels, e.SampleRate = r.Channels, r.SampleRate
+	case *Lame:
+		if r.SampleRate > 0 || r.Channels > 1 {
+			return nil, fmt.Errorf("Rendition %s needs ffmpeg to change the sample rate or channels", r.Name)
+		}
+		e.Mono = r.Channels == 1
+	case WAV:
+		if r.SampleRate > 0 || r.Channels > 0 {
+			return nil, fmt.Errorf("Rendition %s cannot change the sample rate or channels of WAV audio", r.Name)
+		}
+	}
+	return enc, nil
+}
+
+// Path returns the path of the rendition for the output base path.
+func (r Rendition) Path(base, extension string) string {
+	if len(r.Name) == 0 {
+		return base + extension
+	}
+	return base + "-" + r.Name + extension
+}
+
+// CheckPaths returns an error when two of the renditions would be written to
+// the same file for the output base path.
+func CheckPaths(base string, renditions []Rendition) error {
+	seen := make(map[string]bool)
+	for _, r := range renditions {
+		extension := ".wav"
+		if codec, ok := codecs[r.Format]; ok {
+			extension = codec[1]
+		}
+		p := r.Path(base, extension)
+		if seen[p] {
+			return fmt.Errorf("More than one rendition is written to %s, give them different names", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRendition(t *testing.T) {
+	r, err := ParseRendition("mp3,bitrate=48k,channels=1")
+	assert.Nil(t, err)
+	assert.Equal(t, Rendition{Name: "mp3-48k-mono", Format: "mp3", BitRate: 48, Channels: 1}, r)
+	assert.Equal(t, "episode-mp3-48k-mono.mp3", r.Path("episode", ".mp3"))
+
+	r, err = ParseRendition("wav,name=master")
+	assert.Nil(t, err)
+	assert.Equal(t, Rendition{Name: "master", Format: "wav"}, r)
+
+	r, err = ParseRendition("opus,bitrate=32,rate=48000,name=")
+	assert.Nil(t, err)
+	assert.Equal(t, "episode.opus", r.Path("episode", ".opus"))
+	enc, err := r.Encoder("ffmpeg")
+	assert.Nil(t, err)
+	assert.Equal(t, &FFmpeg{Format: "opus", BitRate: 32, SampleRate: 48000}, enc)
+
+	r, err = ParseRendition("aac,name=short,title=Episode 1 (short), comment=The highlights")
+	assert.Nil(t, err)
+	assert.Equal(t, Rendition{Name: "short", Format: "aac", Title: "Episode 1 (short)", Comment: "The highlights"}, r)
+
+	_, err = ParseRendition("flac")
+	assert.NotNil(t, err)
+	_, err = ParseRendition("mp3,bitrate=high")
+	assert.NotNil(t, err)
+	_, err = ParseRendition("mp3,loud")
+	assert.NotNil(t, err)
+}
+
+func TestCheckPaths(t *testing.T) {
+	mp3, _ := ParseRendition("mp3,bitrate=64")
+	mobile, _ := ParseRendition("mp3,bitrate=64,name=mobile")
+	assert.Nil(t, CheckPaths("episode", []Rendition{mp3, mobile}))
+	assert.NotNil(t, CheckPaths("episode", []Rendition{mp3, mobile, mp3}))
+
+	unnamed, _ := ParseRendition("aac,name=")
+	assert.NotNil(t, CheckPaths("episode", []Rendition{{Format: "aac", BitRate: 128}, unnamed}))
+	assert.Nil(t, CheckPaths("episode", []Rendition{{Format: "mp3", BitRate: 128}, unnamed}))
+}
+
+func TestRenditionEncoder(t *testing.T) {
+	enc, err := Rendition{Format: "mp3", BitRate: 64, Channels: 1}.Encoder("lame")
+	assert.Nil(t, err)
+	assert.Equal(t, &Lame{BitRate: 64, Mono: true}, enc)
+
+	_, err = Rendition{Format: "wav", SampleRate: 44100}.Encoder("auto")
+	assert.NotNil(t, err)
+}
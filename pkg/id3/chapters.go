@@ -0,0 +1,54 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Chapter is a part of the audio that players can skip to, written as a
+// CHAP frame and listed in the table of contents.
+type Chapter struct {
+	Title string
+	// URL is an optional link for the chapter.
+	URL   string
+	Start time.Duration
+	End   time.Duration
+}
+
+// chapterFrames returns a CHAP frame for every chapter and a top level CTOC
+// frame that lists them in order, as in the ID3v2 chapter addendum.
+func chapterFrames(version byte, chapters []Chapter) []Frame {
+	if len(chapters) == 0 {
+		return nil
+	}
+	var frames []Frame
+	var toc bytes.Buffer
+	toc.WriteString("toc\x00")
+	// The table of contents is the top level one and is ordered.
+	toc.WriteByte(0x03)
+	toc.WriteByte(byte(len(chapters)))
+	for i, c := range chapters {
+		id := fmt.Sprintf("chp%d", i)
+		toc.WriteString(id + "\x00")
+
+		var body bytes.Buffer
+		body.WriteString(id + "\x00")
+		times := make([]byte, 16)
+		binary.BigEndian.PutUint32(times[0:4], uint32(c.Start/time.Millisecond))
+		binary.BigEndian.PutUint32(times[4:8], uint32(c.End/time.Millisecond))
+		// The byte offsets are not used.
+		binary.BigEndian.PutUint32(times[8:12], 0xFFFFFFFF)
+		binary.BigEndian.PutUint32(times[12:16], 0xFFFFFFFF)
+		body.Write(times)
+		if len(c.Title) > 0 {
+			body.Write(EncodeFrame(version, TextFrame(version, "TIT2", c.Title)))
+		}
+		if len(c.URL) > 0 {
+			body.Write(EncodeFrame(version, urlFrame(version, c.URL)))
+		}
+		frames = append(frames, Frame{ID: "CHAP", Body: body.Bytes()})
+	}
+	return append([]Frame{{ID: "CTOC", Body: toc.Bytes()}}, frames...)
+}
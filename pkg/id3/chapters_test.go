@@ -0,0 +1,29 @@
+package id3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChapters(t *testing.T) {
+	encoded, err := (&Tag{Chapters: []Chapter{
+		{Title: "Intro", Start: 0, End: 1500 * time.Millisecond},
+		{Title: "Story", URL: "https://example.com", Start: 1500 * time.Millisecond, End: 70 * time.Second},
+	}}).Bytes()
+	assert.Nil(t, err)
+	f := frames(t, encoded)
+	assert.Equal(t, []byte("toc\x00\x03\x02chp0\x00chp1\x00"), f["CTOC"])
+
+	// frames keeps the last CHAP frame.
+	chap := f["CHAP"]
+	assert.Equal(t, []byte("chp1\x00"), chap[:5])
+	assert.Equal(t, []byte{0, 0, 0x05, 0xDC, 0, 0x01, 0x11, 0x70, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, chap[5:21])
+	sub := frames(t, append([]byte{'I', 'D', '3', 4, 0, 0}, append(syncsafe(len(chap)-21), chap[21:]...)...))
+	assert.Equal(t, append([]byte{3}, "Story"...), sub["TIT2"])
+	assert.Equal(t, append([]byte{3}, "\x00https://example.com"...), sub["WXXX"])
+
+	_, err = (&Tag{Chapters: make([]Chapter, 256)}).Bytes()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,297 @@
+// Package id3 writes ID3v2.3 and ID3v2.4 tags with the metadata of an
+// episode to the start of an MP3 file.
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"unicode/utf16"
+)
+
+// Tag holds the metadata to write. Empty fields are left out.
+type Tag struct {
+	// Version is the minor version of ID3v2, 3 or 4. Zero means 4.
+	Version byte
+	Title   string
+	Artist  string
+	Album   string
+	// Track is the track number, optionally followed by the number of
+	// tracks, like "3/12".
+	Track string
+	// Date is formatted as yyyy, yyyy-mm or yyyy-mm-dd.
+	Date    string
+	Genre   string
+	Comment string
+	// URL is written as a user defined link.
+	URL string
+	// Lyrics holds the transcript of the episode.
+	Lyrics string
+	// Language is the ISO 639-2 code of the comment and lyrics. Empty means
+	// "eng".
+	Language string
+	Cover    *Picture
+	// Chapters are written as CHAP frames with a table of contents. At most
+	// 255 chapters are supported.
+	Chapters []Chapter
+	// Frames are added after the frames for the fields above.
+	Frames []Frame
+	// Padding is the number of zero bytes after the frames, room to write a
+	// larger tag later without moving the audio.
+	Padding int
+}
+
+// Picture is an image embedded as front cover.
+type Picture struct {
+	MIMEType    string
+	Description string
+	Data        []byte
+}
+
+// Frame is a frame with its body already encoded.
+type Frame struct {
+	ID   string
+	Body []byte
+}
+
+const headerSize = 10
+
+func (t *Tag) version() byte {
+	if t.Version == 0 {
+		return 4
+	}
+	return t.Version
+}
+
+func (t *Tag) language() string {
+	if len(t.Language) != 3 {
+		return "eng"
+	}
+	return t.Language
+}
+
+// Bytes encodes the tag.
+func (t *Tag) Bytes() ([]byte, error) {
+	version := t.version()
+	if version != 3 && version != 4 {
+		return nil, fmt.Errorf("Unsupported ID3v2 version 2.%d, expected 3 or 4", version)
+	}
+	var frames []Frame
+	text := func(id, value string) {
+		if len(value) > 0 {
+			frames = append(frames, TextFrame(version, id, value))
+		}
+	}
+	text("TIT2", t.Title)
+	text("TPE1", t.Artist)
+	text("TALB", t.Album)
+	text("TRCK", t.Track)
+	if version == 4 {
+		text("TDRC", t.Date)
+	} else if len(t.Date) >= 4 {
+		text("TYER", t.Date[:4])
+		if len(t.Date) == 10 {
+			text("TDAT", t.Date[8:10]+t.Date[5:7])
+		}
+	}
+	text("TCON", t.Genre)
+	if len(t.Comment) > 0 {
+		frames = append(frames, Frame{ID: "COMM", Body: languageText(version, t.language(), t.Comment)})
+	}
+	if len(t.URL) > 0 {
+		frames = append(frames, urlFrame(version, t.URL))
+	}
+	if len(t.Lyrics) > 0 {
+		frames = append(frames, Frame{ID: "USLT", Body: languageText(version, t.language(), t.Lyrics)})
+	}
+	if t.Cover != nil {
+		var body bytes.Buffer
+		body.WriteByte(encoding(version))
+		body.WriteString(t.Cover.MIMEType)
+		body.WriteByte(0)
+		// Picture type 3 is the front cover.
+		body.WriteByte(3)
+		body.Write(terminated(version, t.Cover.Description))
+		body.Write(t.Cover.Data)
+		frames = append(frames, Frame{ID: "APIC", Body: body.Bytes()})
+	}
+	if len(t.Chapters) > 255 {
+		return nil, fmt.Errorf("Cannot write %d chapters, the maximum is 255", len(t.Chapters))
+	}
+	frames = append(frames, chapterFrames(version, t.Chapters)...)
+	frames = append(frames, t.Frames...)
+
+	var body bytes.Buffer
+	for _, f := range frames {
+		body.Write(EncodeFrame(version, f))
+	}
+	var tag bytes.Buffer
+	tag.WriteString("ID3")
+	tag.Write([]byte{version, 0, 0})
+	tag.Write(syncsafe(body.Len() + t.Padding))
+	tag.Write(body.Bytes())
+	tag.Write(make([]byte, t.Padding))
+	return tag.Bytes(), nil
+}
+
+// EncodeFrame encodes a frame with its header. The size is a syncsafe
+// integer in ID3v2.4 and a plain integer in ID3v2.3.
+func EncodeFrame(version byte, f Frame) []byte {
+	b := make([]byte, headerSize, headerSize+len(f.Body))
+	copy(b, f.ID)
+	if version == 4 {
+		copy(b[4:8], syncsafe(len(f.Body)))
+	} else {
+		binary.BigEndian.PutUint32(b[4:8], uint32(len(f.Body)))
+	}
+	return append(b, f.Body...)
+}
+
+// TextFrame returns a text information frame like TIT2.
+func TextFrame(version byte, id, value string) Frame {
+	return Frame{ID: id, Body: append([]byte{encoding(version)}, encodeText(version, value)...)}
+}
+
+// urlFrame returns a user defined link frame without description.
+func urlFrame(version byte, url string) Frame {
+	var body bytes.Buffer
+	body.WriteByte(encoding(version))
+	body.Write(terminated(version, ""))
+	body.WriteString(url)
+	return Frame{ID: "WXXX", Body: body.Bytes()}
+}
+
+// encoding returns UTF-8 for ID3v2.4 and UTF-16 with a byte order mark for
+// ID3v2.3, which has no UTF-8.
+func encoding(version byte) byte {
+	if version == 4 {
+		return 3
+	}
+	return 1
+}
+
+func encodeText(version byte, s string) []byte {
+	if version == 4 {
+		return []byte(s)
+	}
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2+2*len(units))
+	b[0], b[1] = 0xFF, 0xFE
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2+2*i:], u)
+	}
+	return b
+}
+
+// terminated encodes s followed by the terminator of the text encoding.
+func terminated(version byte, s string) []byte {
+	if version == 4 {
+		return append(encodeText(version, s), 0)
+	}
+	return append(encodeText(version, s), 0, 0)
+}
+
+// languageText encodes the body of a COMM or USLT frame, with an empty
+// description.
+func languageText(version byte, language, text string) []byte {
+	var body bytes.Buffer
+	body.WriteByte(encoding(version))
+	body.WriteString(language)
+	body.Write(terminated(version, ""))
+	body.Write(encodeText(version, text))
+	return body.Bytes()
+}
+
+func syncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// TagSize returns the size of the ID3v2 tag at the start of header, which
+// holds at least the first 10 bytes of a file, or 0 when there is none.
+func TagSize(header []byte) int {
+	if len(header) < headerSize || string(header[0:3]) != "ID3" {
+		return 0
+	}
+	size := int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9])
+	size += headerSize
+	// A footer is flagged in ID3v2.4.
+	if header[5]&0x10 != 0 {
+		size += headerSize
+	}
+	return size
+}
+
+// WriteFile writes the tag to the start of the MP3 file at path, replacing
+// the ID3v2 tag that the file starts with.
+func WriteFile(path string, tag *Tag) error {
+	encoded, err := tag.Bytes()
+	if err != nil {
+		return err
+	}
+	in, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(in, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	existing := TagSize(header[:n])
+	// A tag that fits in the existing one is written in place, padded to
+	// its size, instead of rewriting the whole file.
+	if existing > 0 && len(encoded) <= existing && header[5]&0x10 == 0 {
+		padded := *tag
+		padded.Padding += existing - len(encoded)
+		if encoded, err = padded.Bytes(); err != nil {
+			return err
+		}
+		if _, err := in.WriteAt(encoded, 0); err != nil {
+			return err
+		}
+		return in.Close()
+	}
+	if _, err := in.Seek(int64(existing), io.SeekStart); err != nil {
+		return err
+	}
+	tmp := path + ".id3.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(encoded); err == nil {
+		_, err = io.Copy(out, in)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	in.Close()
+	return os.Rename(tmp, path)
+}
+
+// ReadCover reads an image file to embed as cover, the MIME type is derived
+// from its content.
+func ReadCover(path string) (*Picture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return &Picture{MIMEType: "image/png", Data: data}, nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return &Picture{MIMEType: "image/jpeg", Data: data}, nil
+	}
+	return nil, errors.New("Cover " + path + " is not a PNG or JPEG image")
+}
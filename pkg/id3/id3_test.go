@@ -0,0 +1,114 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmulholland/mp3lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// frames parses the frames of an encoded tag.
+func frames(t *testing.T, tag []byte) map[string][]byte {
+	found := make(map[string][]byte)
+	body := tag[headerSize:TagSize(tag)]
+	for len(body) >= headerSize && body[0] != 0 {
+		var size int
+		if tag[3] == 4 {
+			size = int(body[4])<<21 | int(body[5])<<14 | int(body[6])<<7 | int(body[7])
+		} else {
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		found[string(body[0:4])] = body[headerSize : headerSize+size]
+		body = body[headerSize+size:]
+	}
+	// Only padding can follow the frames.
+	assert.Equal(t, make([]byte, len(body)), body)
+	return found
+}
+
+func TestVersion4(t *testing.T) {
+	tag := &Tag{
+		Title:   "Show HN: Ünïcode",
+		Artist:  "Hacker News",
+		Track:   "3",
+		Date:    "2019-02-14",
+		Comment: "Top stories",
+		URL:     "https://news.ycombinator.com/item?id=1",
+		Lyrics:  "Hello.",
+		Cover:   &Picture{MIMEType: "image/png", Data: []byte("\x89PNG")},
+	}
+	encoded, err := tag.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, len(encoded), TagSize(encoded))
+	f := frames(t, encoded)
+	assert.Equal(t, append([]byte{3}, "Show HN: Ünïcode"...), f["TIT2"])
+	assert.Equal(t, append([]byte{3}, "2019-02-14"...), f["TDRC"])
+	assert.Equal(t, append([]byte{3}, "eng\x00Top stories"...), f["COMM"])
+	assert.Equal(t, append([]byte{3}, "\x00https://news.ycombinator.com/item?id=1"...), f["WXXX"])
+	assert.Equal(t, append([]byte{3}, "eng\x00Hello."...), f["USLT"])
+	assert.Equal(t, append([]byte{3}, "image/png\x00\x03\x00\x89PNG"...), f["APIC"])
+	assert.NotContains(t, f, "TALB")
+
+	parsed := mp3lib.NextID3v2Tag(bytes.NewReader(encoded))
+	assert.NotNil(t, parsed)
+	assert.Equal(t, encoded, parsed.RawBytes)
+}
+
+func TestVersion3(t *testing.T) {
+	encoded, err := (&Tag{Version: 3, Title: "Hé", Date: "2019-02-14"}).Bytes()
+	assert.Nil(t, err)
+	f := frames(t, encoded)
+	assert.Equal(t, []byte{1, 0xFF, 0xFE, 'H', 0, 0xE9, 0}, f["TIT2"])
+	assert.Equal(t, []byte{1, 0xFF, 0xFE, '2', 0, '0', 0, '1', 0, '9', 0}, f["TYER"])
+	assert.Equal(t, []byte{1, 0xFF, 0xFE, '1', 0, '4', 0, '0', 0, '2', 0}, f["TDAT"])
+
+	_, err = (&Tag{Version: 2}).Bytes()
+	assert.NotNil(t, err)
+}
+
+func TestWriteFileReplacesTag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.mp3")
+	audio := []byte{0xFF, 0xFB, 0x90, 0x00}
+	assert.Nil(t, ioutil.WriteFile(path, audio, 0644))
+
+	assert.Nil(t, WriteFile(path, &Tag{Title: "First"}))
+	assert.Nil(t, WriteFile(path, &Tag{Title: "Second"}))
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	size := TagSize(content)
+	assert.Equal(t, audio, content[size:])
+	assert.Equal(t, append([]byte{3}, "Second"...), frames(t, content[:size])["TIT2"])
+}
+
+func TestWriteFileInPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.mp3")
+	reserved, err := (&Tag{Padding: 256}).Bytes()
+	assert.Nil(t, err)
+	audio := []byte{0xFF, 0xFB, 0x90, 0x00}
+	assert.Nil(t, ioutil.WriteFile(path, append(reserved, audio...), 0644))
+
+	assert.Nil(t, WriteFile(path, &Tag{Title: "Episode"}))
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, len(reserved), TagSize(content))
+	assert.Equal(t, audio, content[len(reserved):])
+	assert.Equal(t, append([]byte{3}, "Episode"...), frames(t, content[:len(reserved)])["TIT2"])
+
+	// A tag that does not fit moves the audio.
+	assert.Nil(t, WriteFile(path, &Tag{Comment: string(make([]byte, 300))}))
+	content, err = ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, TagSize(content) > len(reserved))
+	assert.Equal(t, audio, content[TagSize(content):])
+}
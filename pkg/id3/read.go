@@ -0,0 +1,229 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrNoTag is returned when a file does not start with an ID3v2 tag.
+var ErrNoTag = errors.New("No ID3v2 tag found")
+
+// Read reads the ID3v2.3 or ID3v2.4 tag at the start of r. Frames that have
+// no field in Tag are kept in Frames, except for the table of contents.
+func Read(r io.Reader) (*Tag, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || TagSize(header) == 0 {
+		return nil, ErrNoTag
+	}
+	tag := &Tag{Version: header[3]}
+	if tag.Version != 3 && tag.Version != 4 {
+		return nil, fmt.Errorf("Unsupported ID3v2 version 2.%d, expected 3 or 4", tag.Version)
+	}
+	body := make([]byte, int(header[6])<<21|int(header[7])<<14|int(header[8])<<7|int(header[9]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("Failed to read the ID3v2 tag. Error: %s", err)
+	}
+	// Unsynchronisation inserts a zero byte after every 0xFF.
+	if header[5]&0x80 != 0 {
+		body = bytes.Replace(body, []byte{0xFF, 0x00}, []byte{0xFF}, -1)
+	}
+	// Skip the extended header, its size includes itself in ID3v2.4.
+	if header[5]&0x40 != 0 && len(body) >= 4 {
+		size := int(binary.BigEndian.Uint32(body[0:4]))
+		if tag.Version == 4 {
+			size = int(body[0])<<21 | int(body[1])<<14 | int(body[2])<<7 | int(body[3])
+		} else {
+			size += 4
+		}
+		if size > len(body) {
+			return nil, errors.New("The ID3v2 extended header is larger than the tag")
+		}
+		body = body[size:]
+	}
+	frames, padding := readFrames(tag.Version, body)
+	tag.Padding = padding
+	for _, f := range frames {
+		tag.set(f)
+	}
+	return tag, nil
+}
+
+// ReadFile reads the ID3v2 tag at the start of the file at path.
+func ReadFile(path string) (*Tag, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// readFrames splits the body of a tag into frames, it returns the size of
+// the padding after them.
+func readFrames(version byte, body []byte) ([]Frame, int) {
+	var frames []Frame
+	for len(body) >= headerSize && body[0] != 0 {
+		var size int
+		if version == 4 {
+			size = int(body[4])<<21 | int(body[5])<<14 | int(body[6])<<7 | int(body[7])
+		} else {
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		if size > len(body)-headerSize {
+			break
+		}
+		frames = append(frames, Frame{ID: string(body[0:4]), Body: body[headerSize : headerSize+size]})
+		body = body[headerSize+size:]
+	}
+	return frames, len(body)
+}
+
+// set stores a frame in the field of the tag that it belongs to.
+func (t *Tag) set(f Frame) {
+	text := func() string {
+		if len(f.Body) == 0 {
+			return ""
+		}
+		value, _ := decodeText(f.Body[0], f.Body[1:])
+		return value
+	}
+	switch f.ID {
+	case "TIT2":
+		t.Title = text()
+	case "TPE1":
+		t.Artist = text()
+	case "TALB":
+		t.Album = text()
+	case "TRCK":
+		t.Track = text()
+	case "TDRC":
+		t.Date = text()
+	case "TYER":
+		t.Date = text() + t.Date
+	case "TDAT":
+		if value := text(); len(value) == 4 {
+			t.Date += "-" + value[2:4] + "-" + value[0:2]
+		}
+	case "TCON":
+		t.Genre = text()
+	case "WXXX":
+		if len(f.Body) > 0 {
+			_, rest := decodeText(f.Body[0], f.Body[1:])
+			t.URL = strings.TrimRight(string(rest), "\x00")
+		}
+	case "COMM", "USLT":
+		if len(f.Body) < 4 {
+			return
+		}
+		t.Language = string(f.Body[1:4])
+		_, rest := decodeText(f.Body[0], f.Body[4:])
+		value, _ := decodeText(f.Body[0], rest)
+		if f.ID == "COMM" {
+			t.Comment = value
+		} else {
+			t.Lyrics = value
+		}
+	case "APIC":
+		if len(f.Body) < 2 {
+			return
+		}
+		end := bytes.IndexByte(f.Body[1:], 0)
+		if end < 0 {
+			return
+		}
+		picture := &Picture{MIMEType: string(f.Body[1 : 1+end])}
+		rest := f.Body[2+end:]
+		if len(rest) == 0 {
+			return
+		}
+		// Skip the picture type.
+		description, data := decodeText(f.Body[0], rest[1:])
+		picture.Description = description
+		picture.Data = data
+		t.Cover = picture
+	case "CHAP":
+		if chapter, ok := readChapter(t.Version, f.Body); ok {
+			t.Chapters = append(t.Chapters, chapter)
+		}
+	case "CTOC":
+	default:
+		t.Frames = append(t.Frames, f)
+	}
+}
+
+// readChapter reads the body of a CHAP frame.
+func readChapter(version byte, body []byte) (Chapter, bool) {
+	end := bytes.IndexByte(body, 0)
+	if end < 0 || len(body) < end+1+16 {
+		return Chapter{}, false
+	}
+	times := body[end+1 : end+1+16]
+	chapter := Chapter{
+		Start: time.Duration(binary.BigEndian.Uint32(times[0:4])) * time.Millisecond,
+		End:   time.Duration(binary.BigEndian.Uint32(times[4:8])) * time.Millisecond,
+	}
+	sub := &Tag{Version: version}
+	frames, _ := readFrames(version, body[end+1+16:])
+	for _, f := range frames {
+		sub.set(f)
+	}
+	chapter.Title, chapter.URL = sub.Title, sub.URL
+	return chapter, true
+}
+
+// decodeText decodes text in the given encoding up to its terminator or the
+// end of b, it returns the text and the bytes after the terminator.
+func decodeText(encoding byte, b []byte) (string, []byte) {
+	if encoding == 0 || encoding == 3 {
+		end := bytes.IndexByte(b, 0)
+		if end < 0 {
+			end = len(b)
+		}
+		text, rest := b[:end], after(b, end+1)
+		if encoding == 3 {
+			return string(text), rest
+		}
+		runes := make([]rune, len(text))
+		for i, c := range text {
+			runes[i] = rune(c)
+		}
+		return string(runes), rest
+	}
+	end := len(b) - len(b)%2
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			end = i
+			break
+		}
+	}
+	text, rest := b[:end], after(b, end+2)
+	order := binary.ByteOrder(binary.BigEndian)
+	if len(text) >= 2 {
+		switch {
+		case text[0] == 0xFF && text[1] == 0xFE:
+			order, text = binary.LittleEndian, text[2:]
+		case text[0] == 0xFE && text[1] == 0xFF:
+			text = text[2:]
+		}
+	}
+	units := make([]uint16, len(text)/2)
+	for i := range units {
+		units[i] = order.Uint16(text[2*i:])
+	}
+	return string(utf16.Decode(units)), rest
+}
+
+// after returns the bytes of b from i, or none when b is shorter.
+func after(b []byte, i int) []byte {
+	if i > len(b) {
+		return nil
+	}
+	return b[i:]
+}
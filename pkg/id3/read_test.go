@@ -0,0 +1,44 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWrittenTag(t *testing.T) {
+	for _, version := range []byte{3, 4} {
+		written := &Tag{
+			Version:  version,
+			Title:    "Episode 12 – Ünïcode",
+			Artist:   "Hacker News",
+			Album:    "Everywhere",
+			Track:    "12",
+			Date:     "2019-06-01",
+			Genre:    "Podcast",
+			Comment:  "The stories of the day",
+			URL:      "https://example.com/12",
+			Lyrics:   "First story.\n\nSecond story.",
+			Language: "eng",
+			Cover:    &Picture{MIMEType: "image/png", Data: []byte("\x89PNG data")},
+			Chapters: []Chapter{
+				{Title: "Introduction", Start: 0, End: 5 * time.Second},
+				{Title: "First story", URL: "https://example.com/1", Start: 5 * time.Second, End: 65 * time.Second},
+			},
+			Frames:  []Frame{{ID: "TXXX", Body: []byte{3, 'k', 0, 'v'}}},
+			Padding: 64,
+		}
+		encoded, err := written.Bytes()
+		assert.Nil(t, err)
+		read, err := Read(bytes.NewReader(encoded))
+		assert.Nil(t, err)
+		assert.Equal(t, written, read)
+	}
+}
+
+func TestReadWithoutTag(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte{0xFF, 0xFB, 0x90, 0x00, 0, 0, 0, 0, 0, 0}))
+	assert.Equal(t, ErrNoTag, err)
+}
@@ -0,0 +1,310 @@
+// Package inspect describes audio files: their format, duration, bitrate,
+// tags, chapters and VBR header, to debug the output of the merger and the
+// encoder.
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/audiostats"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/id3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/ogg"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/dmulholland/mp3lib"
+)
+
+// Info describes an audio file.
+type Info struct {
+	Path string `json:"path"`
+	// Format is mp3, wav or ogg, Codec details it, like MPEG-2 layer III
+	// or opus.
+	Format   string        `json:"format"`
+	Codec    string        `json:"codec"`
+	Duration time.Duration `json:"-"`
+	// BitRate is the average bitrate in bits per second.
+	BitRate int  `json:"bitRate"`
+	VBR     bool `json:"vbr"`
+	// BitRates counts the MP3 frames per bitrate.
+	BitRates    map[int]int `json:"bitRates,omitempty"`
+	Frames      int         `json:"frames"`
+	SampleRate  int         `json:"sampleRate"`
+	Channels    int         `json:"channels"`
+	ChannelMode string      `json:"channelMode"`
+	Bytes       int64       `json:"bytes"`
+	// Tag is the ID3v2 tag of an MP3 file.
+	Tag *id3.Tag `json:"-"`
+	// VBRHeader is the Xing header of an MP3 file.
+	VBRHeader *mergemp3.VBRHeader `json:"vbrHeader,omitempty"`
+	// Comments are the comments of an Ogg file.
+	Comments []string `json:"comments,omitempty"`
+}
+
+// File inspects the MP3, WAV or Ogg file at path, the format is detected
+// from its content.
+func File(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(4)
+	info := &Info{Path: path, Bytes: stat.Size()}
+	switch {
+	case bytes.HasPrefix(magic, []byte("RIFF")):
+		err = info.readWAV(r)
+	case bytes.HasPrefix(magic, []byte("OggS")):
+		err = info.readOgg(r)
+	case bytes.HasPrefix(magic, []byte("ID3")), len(magic) >= 2 && magic[0] == 0xFF && magic[1]&0xE0 == 0xE0:
+		err = info.readMP3(r)
+	default:
+		err = errors.New("Unknown audio format, expected MP3, WAV or Ogg")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to inspect %s. Error: %s", path, err)
+	}
+	if info.BitRate == 0 && info.Duration > 0 {
+		info.BitRate = int(float64(info.Bytes*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+func (info *Info) readWAV(r io.Reader) error {
+	audio, err := wav.Decode(r)
+	if err != nil {
+		return err
+	}
+	info.Format, info.Codec = "wav", "PCM 16 bits"
+	info.Duration = audio.Duration()
+	info.Frames = audio.Frames()
+	info.SampleRate = audio.Format.SampleRate
+	info.Channels = audio.Format.Channels
+	info.ChannelMode = channelMode(info.Channels)
+	info.BitRate = info.SampleRate * info.Channels * 16
+	return nil
+}
+
+func (info *Info) readOgg(r io.Reader) error {
+	stream, err := ogg.Read(r)
+	if err != nil {
+		return err
+	}
+	info.Format, info.Codec = "ogg", stream.Codec
+	info.Duration = stream.Duration
+	info.Frames = stream.Pages
+	info.SampleRate = stream.SampleRate
+	if stream.InputSampleRate > 0 {
+		info.SampleRate = stream.InputSampleRate
+	}
+	info.Channels = stream.Channels
+	info.ChannelMode = channelMode(info.Channels)
+	info.Comments = append([]string{"vendor=" + stream.Vendor}, stream.Comments...)
+	return nil
+}
+
+func (info *Info) readMP3(r *bufio.Reader) error {
+	info.Format = "mp3"
+	if header, _ := r.Peek(10); id3.TagSize(header) > 0 {
+		tag, err := id3.Read(r)
+		if err != nil {
+			return err
+		}
+		info.Tag = tag
+	}
+	stats := &audiostats.Result{}
+	isFirstFrame := true
+	for {
+		frame := mp3lib.NextFrame(r)
+		if frame == nil {
+			break
+		}
+		if isFirstFrame {
+			isFirstFrame = false
+			info.Codec = codec(frame)
+			info.SampleRate = frame.SamplingRate
+			info.ChannelMode = map[byte]string{mp3lib.Stereo: "stereo", mp3lib.JointStereo: "joint stereo", mp3lib.DualChannel: "dual channel", mp3lib.Mono: "mono"}[frame.ChannelMode]
+			info.Channels = 2
+			if frame.ChannelMode == mp3lib.Mono {
+				info.Channels = 1
+			}
+			if header, ok := mergemp3.ReadVBRHeader(frame); ok {
+				info.VBRHeader = header
+				continue
+			}
+		}
+		stats.AddBitRate(frame.BitRate, 1)
+		info.Frames++
+		if frame.SamplingRate > 0 {
+			info.Duration += time.Duration(frame.SampleCount) * time.Second / time.Duration(frame.SamplingRate)
+		}
+	}
+	if info.Frames == 0 {
+		return errors.New("No MP3 frames found")
+	}
+	info.BitRates = stats.BitRates
+	info.BitRate = stats.AverageBitRate()
+	info.VBR = stats.IsVBR()
+	return nil
+}
+
+func codec(frame *mp3lib.MP3Frame) string {
+	version := map[byte]string{mp3lib.MPEGVersion1: "1", mp3lib.MPEGVersion2: "2", mp3lib.MPEGVersion2_5: "2.5"}[frame.MPEGVersion]
+	layer := map[byte]string{mp3lib.MPEGLayerI: "I", mp3lib.MPEGLayerII: "II", mp3lib.MPEGLayerIII: "III"}[frame.MPEGLayer]
+	return fmt.Sprintf("MPEG-%s layer %s", version, layer)
+}
+
+func channelMode(channels int) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	}
+	return fmt.Sprintf("%d channels", channels)
+}
+
+// String formats the information for humans.
+func (info *Info) String() string {
+	var b strings.Builder
+	line := func(name, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "  %-13s%s\n", name+":", fmt.Sprintf(format, args...))
+	}
+	fmt.Fprintf(&b, "%s\n", info.Path)
+	line("Format", "%s, %s", info.Format, info.Codec)
+	line("Duration", "%s", timestamp(info.Duration))
+	kind := "CBR"
+	if info.VBR {
+		kind = "VBR"
+	}
+	line("Bitrate", "%d kbps %s", info.BitRate/1000, kind)
+	if len(info.BitRates) > 1 {
+		var rates []int
+		for rate := range info.BitRates {
+			rates = append(rates, rate)
+		}
+		sort.Ints(rates)
+		var counts []string
+		for _, rate := range rates {
+			counts = append(counts, fmt.Sprintf("%d kbps × %d", rate/1000, info.BitRates[rate]))
+		}
+		line("Bitrates", "%s", strings.Join(counts, ", "))
+	}
+	frames := "Frames"
+	if info.Format == "ogg" {
+		frames = "Pages"
+	}
+	line(frames, "%d", info.Frames)
+	line("Sample rate", "%d Hz", info.SampleRate)
+	line("Channels", "%d, %s", info.Channels, info.ChannelMode)
+	line("Size", "%d bytes", info.Bytes)
+	if h := info.VBRHeader; h != nil {
+		line(h.ID+" header", "%d frames, %d bytes, seek table: %t", h.Frames, h.Bytes, len(h.TOC) > 0)
+		if len(h.Encoder) > 0 {
+			line("LAME tag", "%s, delay %d, padding %d samples", h.Encoder, h.Delay, h.Padding)
+		}
+	}
+	if t := info.Tag; t != nil {
+		fmt.Fprintf(&b, "  ID3v2.%d tag:\n", t.Version)
+		field := func(name, value string) {
+			if len(value) > 0 {
+				fmt.Fprintf(&b, "    %-10s%s\n", name+":", strings.Replace(value, "\n", " ", -1))
+			}
+		}
+		field("Title", t.Title)
+		field("Artist", t.Artist)
+		field("Album", t.Album)
+		field("Track", t.Track)
+		field("Date", t.Date)
+		field("Genre", t.Genre)
+		field("Comment", t.Comment)
+		field("URL", t.URL)
+		if len(t.Lyrics) > 0 {
+			field("Lyrics", fmt.Sprintf("%d characters", len(t.Lyrics)))
+		}
+		if t.Cover != nil {
+			field("Cover", fmt.Sprintf("%s, %d bytes", t.Cover.MIMEType, len(t.Cover.Data)))
+		}
+		for _, f := range t.Frames {
+			field(f.ID, fmt.Sprintf("%d bytes", len(f.Body)))
+		}
+		if t.Padding > 0 {
+			field("Padding", fmt.Sprintf("%d bytes", t.Padding))
+		}
+		if len(t.Chapters) > 0 {
+			fmt.Fprintf(&b, "  Chapters:\n")
+			for _, c := range t.Chapters {
+				fmt.Fprintf(&b, "    %s %s\n", timestamp(c.Start), c.Title)
+			}
+		}
+	}
+	for _, c := range info.Comments {
+		line("Comment", "%s", c)
+	}
+	return b.String()
+}
+
+// timestamp formats a duration as hh:mm:ss.mmm.
+func timestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, int(d/time.Millisecond)%1000)
+}
+
+type jsonChapter struct {
+	Title string  `json:"title"`
+	URL   string  `json:"url,omitempty"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+type jsonTag struct {
+	Version  byte              `json:"version"`
+	Fields   map[string]string `json:"fields"`
+	Cover    string            `json:"cover,omitempty"`
+	Frames   []string          `json:"frames,omitempty"`
+	Padding  int               `json:"padding"`
+	Chapters []jsonChapter     `json:"chapters,omitempty"`
+}
+
+// MarshalJSON formats the information as json, with the times in seconds.
+func (info *Info) MarshalJSON() ([]byte, error) {
+	type plain Info
+	out := struct {
+		*plain
+		Duration float64  `json:"duration"`
+		Tag      *jsonTag `json:"tag,omitempty"`
+	}{plain: (*plain)(info), Duration: info.Duration.Seconds()}
+	if t := info.Tag; t != nil {
+		out.Tag = &jsonTag{Version: t.Version, Fields: map[string]string{}, Padding: t.Padding}
+		for name, value := range map[string]string{
+			"title": t.Title, "artist": t.Artist, "album": t.Album, "track": t.Track, "date": t.Date,
+			"genre": t.Genre, "comment": t.Comment, "url": t.URL, "lyrics": t.Lyrics, "language": t.Language,
+		} {
+			if len(value) > 0 {
+				out.Tag.Fields[name] = value
+			}
+		}
+		if t.Cover != nil {
+			out.Tag.Cover = fmt.Sprintf("%s, %d bytes", t.Cover.MIMEType, len(t.Cover.Data))
+		}
+		for _, f := range t.Frames {
+			out.Tag.Frames = append(out.Tag.Frames, f.ID)
+		}
+		for _, c := range t.Chapters {
+			out.Tag.Chapters = append(out.Tag.Chapters, jsonChapter{Title: c.Title, URL: c.URL, Start: c.Start.Seconds(), End: c.End.Seconds()})
+		}
+	}
+	return json.Marshal(out)
+}
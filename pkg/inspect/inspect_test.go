@@ -0,0 +1,87 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/id3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3/mp3test"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectMP3(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspect")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	first, second, out := filepath.Join(dir, "0.mp3"), filepath.Join(dir, "1.mp3"), filepath.Join(dir, "out.mp3")
+	assert.Nil(t, ioutil.WriteFile(first, bytes.Repeat(mp3test.Frame(9), 10), 0644))
+	assert.Nil(t, ioutil.WriteFile(second, bytes.Repeat(mp3test.Frame(5), 10), 0644))
+	_, err = mergemp3.Merge(context.Background(), out, []string{first, second}, mergemp3.Options{})
+	assert.Nil(t, err)
+	tag := &id3.Tag{Title: "Episode", Chapters: []id3.Chapter{{Title: "Introduction", End: time.Second}}}
+	assert.Nil(t, id3.WriteFile(out, tag))
+
+	info, err := File(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "mp3", info.Format)
+	assert.Equal(t, "MPEG-1 layer III", info.Codec)
+	assert.Equal(t, 20, info.Frames)
+	assert.Equal(t, 20*(1152*time.Second/44100), info.Duration)
+	assert.True(t, info.VBR)
+	assert.Equal(t, map[int]int{128000: 10, 64000: 10}, info.BitRates)
+	assert.Equal(t, 44100, info.SampleRate)
+	assert.Equal(t, "stereo", info.ChannelMode)
+	assert.Equal(t, "Xing", info.VBRHeader.ID)
+	assert.Equal(t, uint32(20), info.VBRHeader.Frames)
+	assert.Equal(t, "Episode", info.Tag.Title)
+	assert.Equal(t, "Introduction", info.Tag.Chapters[0].Title)
+
+	text := info.String()
+	assert.Contains(t, text, "Xing header: 20 frames")
+	assert.Contains(t, text, "00:00:00.000 Introduction")
+
+	encoded, err := json.Marshal(info)
+	assert.Nil(t, err)
+	var decoded map[string]interface{}
+	assert.Nil(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, info.Duration.Seconds(), decoded["duration"])
+	assert.Equal(t, "Episode", decoded["tag"].(map[string]interface{})["fields"].(map[string]interface{})["title"])
+	header := decoded["vbrHeader"].(map[string]interface{})
+	assert.Equal(t, "Xing", header["id"])
+	assert.Equal(t, float64(20), header["frames"])
+	assert.Equal(t, 100, len(header["toc"].([]interface{})))
+	assert.IsType(t, float64(0), header["toc"].([]interface{})[0])
+}
+
+func TestInspectWAV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspect")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "speech.wav")
+	assert.Nil(t, wav.WriteFile(path, &wav.Audio{Format: wav.Format{SampleRate: 24000, Channels: 1}, Samples: make([]int16, 48000)}))
+
+	info, err := File(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "wav", info.Format)
+	assert.Equal(t, 2*time.Second, info.Duration)
+	assert.Equal(t, 384000, info.BitRate)
+	assert.Equal(t, "mono", info.ChannelMode)
+}
+
+func TestInspectUnknown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspect")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notes.txt")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("not audio"), 0644))
+	_, err = File(path)
+	assert.NotNil(t, err)
+}
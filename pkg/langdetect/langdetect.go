@@ -0,0 +1,143 @@
+// Package langdetect detects the language of a piece of text offline, by
+// comparing its character trigrams with the trigram profiles of a set of
+// languages.
+package langdetect
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minLetters is the number of letters that a text needs to have for its
+// language to be detected.
+const minLetters = 20
+
+// minMargin is the minimum difference in average log probability per
+// trigram between the best and second best language. Below it the text is
+// considered ambiguous.
+const minMargin = 0.05
+
+// minSwitchLetters and minSwitchMargin are the stricter limits of
+// DetectOther, which is used to switch away from a language that is expected.
+const (
+	minSwitchLetters = 40
+	minSwitchMargin  = 0.25
+)
+
+type profile struct {
+	counts map[string]int
+	total  int
+}
+
+var profiles = map[string]*profile{}
+
+func init() {
+	for language, sample := range samples {
+		p := &profile{counts: map[string]int{}}
+		for _, t := range trigrams(sample) {
+			p.counts[t]++
+			p.total++
+		}
+		profiles[language] = p
+	}
+}
+
+// Languages returns the ISO 639-1 codes of the languages that can be
+// detected.
+func Languages() []string {
+	var languages []string
+	for language := range profiles {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Detect returns the ISO 639-1 code of the language that text is most likely
+// written in, like "en" or "de". It returns an empty string when text is too
+// short or too ambiguous to tell.
+func Detect(text string) string {
+	if countLetters(text) < minLetters {
+		return ""
+	}
+	language, best, second := detect(trigrams(text))
+	if best-second < minMargin {
+		return ""
+	}
+	return language
+}
+
+// DetectOther returns the language of text when it is clearly more likely
+// than the expected language, and an empty string otherwise. Text in the
+// expected language, like technical prose full of loanwords, is kept in
+// that language unless another one wins by a wide margin.
+func DetectOther(text, expected string) string {
+	p := profiles[expected]
+	if p == nil {
+		return Detect(text)
+	}
+	if countLetters(text) < minSwitchLetters {
+		return ""
+	}
+	ts := trigrams(text)
+	language, best, _ := detect(ts)
+	if language == expected || best-p.score(ts) < minSwitchMargin {
+		return ""
+	}
+	return language
+}
+
+// detect returns the most likely language for the trigrams with its score
+// and the score of the second best language.
+func detect(ts []string) (language string, best, second float64) {
+	best, second = math.Inf(-1), math.Inf(-1)
+	for _, l := range Languages() {
+		score := profiles[l].score(ts)
+		if score > best {
+			best, second, language = score, best, l
+		} else if score > second {
+			second = score
+		}
+	}
+	return language, best, second
+}
+
+func countLetters(text string) int {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters
+}
+
+// score returns the average log probability of the trigrams under the
+// profile, with add-one smoothing for trigrams the profile has not seen.
+func (p *profile) score(trigrams []string) float64 {
+	vocabulary := float64(len(p.counts) + 1)
+	var sum float64
+	for _, t := range trigrams {
+		sum += math.Log(float64(p.counts[t]+1) / (float64(p.total) + vocabulary))
+	}
+	return sum / float64(len(trigrams))
+}
+
+// trigrams returns the character trigrams of the words in text. Words are
+// lowercased and padded with a space on both sides, so that the trigrams
+// capture the starts and ends of words.
+func trigrams(text string) []string {
+	var ts []string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		runes := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			ts = append(ts, string(runes[i:i+3]))
+		}
+	}
+	return ts
+}
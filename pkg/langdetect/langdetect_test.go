@@ -0,0 +1,46 @@
+package langdetect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, "en", Detect("The upper echelon is hoarding money and privilege to a degree not seen in decades."))
+	assert.Equal(t, "de", Detect("Der Mensch ist nicht dazu geschaffen, sich über die Maßen mit Zahlen zu beschäftigen."))
+	assert.Equal(t, "fr", Detect("L'essentiel est invisible pour les yeux, on ne voit bien qu'avec le cœur."))
+	assert.Equal(t, "nl", Detect("Wie het kleine niet eert, is het grote niet weerd, zei mijn grootmoeder altijd."))
+	assert.Equal(t, "es", Detect("En un lugar de la Mancha, de cuyo nombre no quiero acordarme, vivía un hidalgo."))
+	assert.Equal(t, "it", Detect("Nel mezzo del cammin di nostra vita mi ritrovai per una selva oscura."))
+}
+
+func TestDetectTechnicalEnglish(t *testing.T) {
+	for _, text := range []string{
+		"Kubernetes operators reconcile desired state with actual state in a control loop.",
+		"The scheduler assigns pods to nodes based on resource requests and affinity rules.",
+		"Use a mutex to protect the map from concurrent writes in goroutines.",
+		"PostgreSQL supports transactional DDL, so migrations can be rolled back atomically.",
+		"Rust borrow checker prevents data races at compile time.",
+	} {
+		assert.Equal(t, "en", Detect(text), text)
+		assert.Equal(t, "", DetectOther(text, "en"), text)
+	}
+}
+
+func TestDetectOther(t *testing.T) {
+	assert.Equal(t, "de", DetectOther("Der Mensch ist nicht dazu geschaffen, sich über die Maßen mit Zahlen zu beschäftigen.", "en"))
+	assert.Equal(t, "it", DetectOther("Nel mezzo del cammin di nostra vita mi ritrovai per una selva oscura.", "en"))
+	assert.Equal(t, "", DetectOther("Der Mensch ist nicht dazu geschaffen, sich über die Maßen mit Zahlen zu beschäftigen.", "de"))
+	assert.Equal(t, "", DetectOther("Wie het kleine niet eert, is het.", "en"))
+	assert.Equal(t, "fr", DetectOther("L'essentiel est invisible pour les yeux, on ne voit bien qu'avec le cœur.", "ja"))
+}
+
+func TestDetectShortText(t *testing.T) {
+	assert.Equal(t, "", Detect("Ja, genau."))
+	assert.Equal(t, "", Detect("1, 2, 3"))
+}
+
+func TestLanguages(t *testing.T) {
+	assert.Equal(t, []string{"de", "en", "es", "fr", "it", "nl"}, Languages())
+}
@@ -0,0 +1,172 @@
+package langdetect
+
+// samples holds a sample of ordinary and technical prose for every supported
+// language. The trigram profiles of the languages are built from these texts.
+var samples = map[string]string{
+	"en": `The first week of the new project felt like a good time for everyone
+on the team. We had a clear plan, enough money and the support of the people
+who would use what we were going to build. Nobody expected that the hardest
+part of the work would not be the technology but the way we talked with each
+other. When something went wrong, it was usually because one of us had made an
+assumption that the others did not share. Over the following months we learned
+to write down what we thought, to ask questions early and to show our work
+before it was finished. The result was not perfect, but it was better than
+anything we could have made alone. Looking back, the most important thing we
+did was to take the time to understand the problem before trying to solve it.
+There is always a temptation to start with the answer, and it is almost always
+wrong. What would you do differently if you had to start again today? This is
+the question that I keep asking myself, and the answer changes every year.
+
+Our service runs on a small cluster of servers in two data centers. Every
+request that reaches the application is checked, stored in a database and
+written to a log, so that we can find out later what happened. When the load
+grows, the system starts new instances automatically and stops them again when
+they are no longer needed. The configuration of every component is kept in
+version control, and each change is reviewed by another developer before it is
+deployed. We measure how long the important operations take and we are warned
+when the error rate or the response time is higher than usual. Most problems
+are not caused by the code itself but by a change in the environment: a full
+disk, an expired certificate or a network connection that is slower than
+expected.`,
+
+	"de": `Die erste Woche des neuen Projekts war für alle im Team eine gute
+Zeit. Wir hatten einen klaren Plan, genug Geld und die Unterstützung der
+Menschen, die das nutzen würden, was wir bauen wollten. Niemand hatte erwartet,
+dass der schwierigste Teil der Arbeit nicht die Technik sein würde, sondern die
+Art und Weise, wie wir miteinander gesprochen haben. Wenn etwas schiefging, lag
+es meistens daran, dass einer von uns eine Annahme gemacht hatte, die die
+anderen nicht teilten. In den folgenden Monaten haben wir gelernt, unsere
+Gedanken aufzuschreiben, früh Fragen zu stellen und unsere Arbeit zu zeigen,
+bevor sie fertig war. Das Ergebnis war nicht perfekt, aber es war besser als
+alles, was wir allein hätten machen können. Im Rückblick war das Wichtigste,
+dass wir uns die Zeit genommen haben, das Problem zu verstehen, bevor wir
+versucht haben, es zu lösen. Es gibt immer die Versuchung, mit der Antwort zu
+beginnen, und sie ist fast immer falsch. Was würden Sie heute anders machen,
+wenn Sie noch einmal von vorne anfangen müssten? Diese Frage stelle ich mir
+immer wieder, und die Antwort ändert sich jedes Jahr.
+
+Unser Dienst läuft auf einem kleinen Cluster von Servern in zwei
+Rechenzentren. Jede Anfrage, die die Anwendung erreicht, wird geprüft, in
+einer Datenbank gespeichert und in ein Protokoll geschrieben, damit wir später
+herausfinden können, was passiert ist. Wenn die Last steigt, startet das
+System automatisch neue Instanzen und beendet sie wieder, wenn sie nicht mehr
+gebraucht werden. Die Konfiguration jeder Komponente wird in der
+Versionsverwaltung gehalten, und jede Änderung wird von einem anderen
+Entwickler geprüft, bevor sie ausgeliefert wird. Wir messen, wie lange die
+wichtigen Vorgänge dauern, und werden gewarnt, wenn die Fehlerrate oder die
+Antwortzeit höher ist als sonst. Die meisten Probleme werden nicht vom Code
+selbst verursacht, sondern von einer Änderung in der Umgebung: eine volle
+Festplatte, ein abgelaufenes Zertifikat oder eine Netzwerkverbindung, die
+langsamer ist als erwartet.`,
+
+	"fr": `La première semaine du nouveau projet a été un bon moment pour toute
+l'équipe. Nous avions un plan clair, assez d'argent et le soutien des personnes
+qui allaient utiliser ce que nous voulions construire. Personne ne s'attendait
+à ce que la partie la plus difficile du travail ne soit pas la technique, mais
+la façon dont nous parlions les uns avec les autres. Quand quelque chose
+tournait mal, c'était généralement parce que l'un d'entre nous avait fait une
+supposition que les autres ne partageaient pas. Au cours des mois suivants, nous
+avons appris à écrire ce que nous pensions, à poser des questions tôt et à
+montrer notre travail avant qu'il ne soit terminé. Le résultat n'était pas
+parfait, mais il était meilleur que tout ce que nous aurions pu faire seuls. Avec
+le recul, la chose la plus importante que nous ayons faite a été de prendre le
+temps de comprendre le problème avant d'essayer de le résoudre. Il y a toujours
+la tentation de commencer par la réponse, et elle est presque toujours fausse.
+Que feriez-vous différemment si vous deviez recommencer aujourd'hui? C'est la
+question que je me pose encore, et la réponse change chaque année.
+
+Notre service fonctionne sur un petit groupe de serveurs dans deux centres de
+données. Chaque requête qui arrive à l'application est vérifiée, enregistrée
+dans une base de données et écrite dans un journal, afin que nous puissions
+savoir plus tard ce qui s'est passé. Quand la charge augmente, le système
+démarre automatiquement de nouvelles instances et les arrête quand elles ne
+sont plus nécessaires. La configuration de chaque composant est conservée dans
+le contrôle de version, et chaque modification est relue par un autre
+développeur avant d'être déployée. Nous mesurons la durée des opérations
+importantes et nous sommes avertis quand le taux d'erreurs ou le temps de
+réponse est plus élevé que d'habitude. La plupart des problèmes ne viennent
+pas du code lui-même mais d'un changement de l'environnement : un disque
+plein, un certificat expiré ou une connexion réseau plus lente que prévu.`,
+
+	"nl": `De eerste week van het nieuwe project was voor iedereen in het team
+een goede tijd. We hadden een duidelijk plan, genoeg geld en de steun van de
+mensen die zouden gebruiken wat we gingen bouwen. Niemand had verwacht dat het
+moeilijkste deel van het werk niet de techniek zou zijn, maar de manier waarop
+we met elkaar praatten. Als er iets misging, kwam dat meestal doordat een van
+ons een aanname had gedaan die de anderen niet deelden. In de maanden daarna
+hebben we geleerd om op te schrijven wat we dachten, om vroeg vragen te stellen
+en om ons werk te laten zien voordat het af was. Het resultaat was niet
+perfect, maar het was beter dan alles wat we alleen hadden kunnen maken. Achteraf
+gezien was het belangrijkste dat we de tijd hebben genomen om het probleem te
+begrijpen voordat we het probeerden op te lossen. Er is altijd de verleiding om
+met het antwoord te beginnen, en dat is bijna altijd fout. Wat zou je vandaag
+anders doen als je opnieuw moest beginnen? Dat is de vraag die ik mezelf blijf
+stellen, en het antwoord verandert elk jaar.
+
+Onze dienst draait op een kleine groep servers in twee datacenters. Elk
+verzoek dat de applicatie bereikt, wordt gecontroleerd, in een database
+opgeslagen en naar een logbestand geschreven, zodat we later kunnen nagaan wat
+er gebeurd is. Als de belasting toeneemt, start het systeem automatisch nieuwe
+instanties en stopt ze weer wanneer ze niet meer nodig zijn. De configuratie
+van elk onderdeel wordt bijgehouden in versiebeheer, en elke wijziging wordt
+door een andere ontwikkelaar bekeken voordat ze in gebruik wordt genomen. We
+meten hoe lang de belangrijke handelingen duren en we krijgen een waarschuwing
+als het aantal fouten of de reactietijd hoger is dan normaal. De meeste
+problemen worden niet door de code zelf veroorzaakt, maar door een verandering
+in de omgeving: een volle schijf, een verlopen certificaat of een
+netwerkverbinding die trager is dan verwacht.`,
+
+	"es": `La primera semana del nuevo proyecto fue un buen momento para todo el
+equipo. Teníamos un plan claro, suficiente dinero y el apoyo de las personas
+que iban a usar lo que queríamos construir. Nadie esperaba que la parte más
+difícil del trabajo no fuera la tecnología, sino la forma en que hablábamos
+entre nosotros. Cuando algo salía mal, normalmente era porque uno de nosotros
+había hecho una suposición que los demás no compartían. En los meses siguientes
+aprendimos a escribir lo que pensábamos, a hacer preguntas pronto y a mostrar
+nuestro trabajo antes de que estuviera terminado. El resultado no fue perfecto,
+pero fue mejor que cualquier cosa que hubiéramos podido hacer solos. Mirando
+hacia atrás, lo más importante que hicimos fue tomarnos el tiempo para entender
+el problema antes de intentar resolverlo. Siempre existe la tentación de empezar
+por la respuesta, y casi siempre es incorrecta. ¿Qué harías de otra manera si
+tuvieras que empezar de nuevo hoy? Es la pregunta que me sigo haciendo, y la
+respuesta cambia cada año.
+
+Nuestro servicio funciona en un pequeño grupo de servidores en dos centros de
+datos. Cada petición que llega a la aplicación se comprueba, se guarda en una
+base de datos y se escribe en un registro, para que podamos averiguar más
+tarde lo que ha pasado. Cuando la carga crece, el sistema arranca nuevas
+instancias de forma automática y las detiene cuando ya no hacen falta. La
+configuración de cada componente se guarda en el control de versiones, y otro
+desarrollador revisa cada cambio antes de que se despliegue. Medimos cuánto
+tardan las operaciones importantes y recibimos un aviso cuando la tasa de
+errores o el tiempo de respuesta es más alto de lo normal. La mayoría de los
+problemas no los causa el código en sí, sino un cambio en el entorno: un disco
+lleno, un certificado caducado o una conexión de red más lenta de lo esperado.`,
+
+	"it": `La prima settimana del nuovo progetto è stata un bel periodo per tutta
+la squadra. Avevamo un piano chiaro, abbastanza soldi e il sostegno delle
+persone che avrebbero usato quello che volevamo costruire. Nessuno si aspettava
+che la parte più difficile del lavoro non fosse la tecnologia, ma il modo in cui
+parlavamo tra di noi. Quando qualcosa andava storto, di solito era perché uno di
+noi aveva fatto un'ipotesi che gli altri non condividevano. Nei mesi successivi
+abbiamo imparato a scrivere quello che pensavamo, a fare domande presto e a
+mostrare il nostro lavoro prima che fosse finito. Il risultato non era perfetto,
+ma era migliore di qualsiasi cosa avremmo potuto fare da soli. Guardando
+indietro, la cosa più importante che abbiamo fatto è stata prenderci il tempo
+per capire il problema prima di cercare di risolverlo. C'è sempre la tentazione
+di cominciare dalla risposta, e quasi sempre è sbagliata. Che cosa faresti di
+diverso se dovessi ricominciare oggi? È la domanda che continuo a farmi, e la
+risposta cambia ogni anno.
+
+Il nostro servizio gira su un piccolo gruppo di server in due centri di
+calcolo. Ogni richiesta che arriva all'applicazione viene controllata, salvata
+in una base di dati e scritta in un registro, così possiamo scoprire più tardi
+che cosa è successo. Quando il carico cresce, il sistema avvia automaticamente
+nuove istanze e le ferma quando non servono più. La configurazione di ogni
+componente è conservata nel controllo di versione, e ogni modifica viene letta
+da un altro sviluppatore prima di essere rilasciata. Misuriamo quanto durano
+le operazioni importanti e veniamo avvisati quando il tasso di errori o il
+tempo di risposta è più alto del solito. La maggior parte dei problemi non è
+causata dal codice stesso, ma da un cambiamento nell'ambiente: un disco pieno,
+un certificato scaduto o una connessione di rete più lenta del previsto.`,
+}
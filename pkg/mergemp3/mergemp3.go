@@ -1,218 +1,354 @@
 package mergemp3
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/audiostats"
 	"github.com/dmulholland/mp3lib"
 )
 
-// Create a new file at the specified output path containing the merged
-// contents of the list of input files.
-func Merge(outpath string, inpaths []string, force, tag bool) {
-	var totalFrames uint32
-	var totalBytes uint32
-	var totalFiles int
-	var firstBitRate int
-	var isVBR bool
-
-	// Only overwrite an existing file if the --force flag has been used.
-	if _, err := os.Stat(outpath); err == nil {
-		if !force {
-			fmt.Fprintf(
-				os.Stderr,
-				"Error: the file '%v' already exists.\n", outpath)
-			os.Exit(1)
-		}
+// Input is an MP3 stream to merge, Name identifies it in the result and the
+// progress reports.
+type Input struct {
+	Name   string
+	Reader io.Reader
+	// Delay and Padding are the encoder delay and padding in samples of a
+	// stream without a VBR header frame to read them from, like a span of
+	// frames cut out of a file. A LAME tag in the stream takes precedence.
+	Delay   int
+	Padding int
+}
+
+// Progress is reported after every input that is merged.
+type Progress struct {
+	// Input is the index of the merged input and Inputs the number of inputs.
+	Input  int
+	Inputs int
+	Name   string
+	// Frames is the number of frames merged so far.
+	Frames int
+}
+
+// Options control a merge.
+type Options struct {
+	// Force overwrites an existing output file.
+	Force bool
+	// Tag copies the ID3v2 tag of the first input to the output.
+	Tag bool
+	// Header is written at the start of the output instead of the tag of
+	// the first input, like a padded ID3v2 tag that is filled in after the
+	// merge without rewriting the file.
+	Header []byte
+	// Progress, when set, is called after every input that is merged.
+	Progress func(Progress)
+	// Repair drops the frames that are corrupt or in another format than
+	// the stream and strips stray tags and junk, instead of failing with a
+	// Report. Inputs in another format than the first input always fail.
+	Repair bool
+	// Problem, when set, is called for every defect that is repaired.
+	Problem func(Problem)
+}
+
+// Merge creates a new file at outpath containing the merged contents of the
+// input files. Returns the durations, offsets and frame statistics of the
+// merged file. The output file is removed when the merge fails.
+func Merge(ctx context.Context, outpath string, inpaths []string, opts Options) (*audiostats.Result, error) {
+	// Only overwrite an existing file if the force option has been used.
+	if _, err := os.Stat(outpath); err == nil && !opts.Force {
+		return nil, fmt.Errorf("The file '%v' already exists", outpath)
 	}
 
 	// If the list of input files includes the output file we'll end up in an
 	// infinite loop.
-	for _, filepath := range inpaths {
-		if filepath == outpath {
-			fmt.Fprintln(
-				os.Stderr,
-				"Error: the list of input files includes the output file.")
-			os.Exit(1)
+	for _, inpath := range inpaths {
+		if inpath == outpath {
+			return nil, errors.New("The list of input files includes the output file")
+		}
+	}
+
+	// The inputs are opened one at a time while they are merged, so that a
+	// merge of many segments does not hold a file descriptor for each.
+	var inputs []Input
+	for _, inpath := range inpaths {
+		if _, err := os.Stat(inpath); err != nil {
+			return nil, err
 		}
+		infile := &lazyFile{path: inpath}
+		defer infile.Close()
+		inputs = append(inputs, Input{Name: inpath, Reader: infile})
 	}
 
-	// Create the output file.
+	return mergeFile(ctx, outpath, inputs, opts)
+}
+
+// mergeFile merges the inputs to a new file at outpath, which is removed
+// when the merge fails.
+func mergeFile(ctx context.Context, outpath string, inputs []Input, opts Options) (*audiostats.Result, error) {
 	outfile, err := os.Create(outpath)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return nil, err
 	}
+	result, err := MergeStreams(ctx, outfile, inputs, opts)
+	if closeErr := outfile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outpath)
+		return nil, err
+	}
+	return result, nil
+}
 
-	// Loop over the input files and append their MP3 frames to the output
-	// file.
-	for _, inpath := range inpaths {
-
-		fmt.Println("+", inpath)
+// lazyFile reads a file that is opened on the first Read and closed at its
+// end or at the first error.
+type lazyFile struct {
+	path string
+	file *os.File
+	err  error
+}
 
-		infile, err := os.Open(inpath)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+func (f *lazyFile) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if f.file == nil {
+		if f.file, f.err = os.Open(f.path); f.err != nil {
+			return 0, f.err
 		}
+	}
+	n, err := f.file.Read(p)
+	if err != nil {
+		f.err = err
+		f.Close()
+	}
+	return n, err
+}
 
-		isFirstFrame := true
+// Close closes the file when it is open.
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
 
-		for {
+// MergeStreams writes the MP3 frames of the inputs to out and returns the
+// statistics of the merged stream in a single pass. A Xing header frame is
+// reserved before the frames, after the ID3v2 tag or opts.Header, and
+// filled in once all frames are written. The merge stops when ctx is done.
+func MergeStreams(ctx context.Context, out io.WriteSeeker, inputs []Input, opts Options) (*audiostats.Result, error) {
+	var header *vbrHeader
+	var headerOffset int64
+	var stream format
+	var report Report
+	delay, padding := 0, 0
+	result := &audiostats.Result{}
+
+	if len(opts.Header) > 0 {
+		if _, err := out.Write(opts.Header); err != nil {
+			return nil, fmt.Errorf("Failed to write the header. Error: %s", err)
+		}
+	}
 
-			// Read the next frame from the input file.
-			frame := mp3lib.NextFrame(infile)
-			if frame == nil {
-				break
+	// Loop over the inputs and append their MP3 frames to the output.
+	for i, in := range inputs {
+		isFirstFrame := true
+		if i == 0 {
+			delay = in.Delay
+		}
+		if i == len(inputs)-1 {
+			padding = in.Padding
+		}
+		input := audiostats.Input{Path: in.Name}
+		problem := func(offset int64, message string, args ...interface{}) {
+			p := Problem{Input: in.Name, Offset: offset, Message: fmt.Sprintf(message, args...)}
+			if opts.Repair && opts.Problem != nil {
+				opts.Problem(p)
 			}
+			report = append(report, p)
+		}
 
-			// Skip the first frame if it's a VBR header.
-			if isFirstFrame {
-				isFirstFrame = false
-				if mp3lib.IsXingHeader(frame) || mp3lib.IsVbriHeader(frame) {
-					continue
-				}
+		// A frame is written once the next object is found right after
+		// it, a frame that is followed by junk is truncated or corrupt.
+		var pending *mp3lib.MP3Frame
+		var pendingOffset int64
+		write := func() error {
+			frame := pending
+			pending = nil
+			if frame == nil || len(report) > 0 && !opts.Repair {
+				return nil
 			}
 
-			// If we detect more than one bitrate we'll need to add a VBR
-			// header to the output file.
-			if firstBitRate == 0 {
-				firstBitRate = frame.BitRate
-			} else if frame.BitRate != firstBitRate {
-				isVBR = true
+			// Reserve room for the VBR header, in the format of the first
+			// frame.
+			if header == nil {
+				header = newVBRHeader(frame)
+				offset, err := out.Seek(0, io.SeekCurrent)
+				if err != nil {
+					return err
+				}
+				headerOffset = offset
+				if _, err := out.Write(header.frame()); err != nil {
+					return fmt.Errorf("Failed to write the VBR header. Error: %s", err)
+				}
 			}
 
-			// Write the frame to the output file.
-			_, err := outfile.Write(frame.RawBytes)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
+			// Write the frame to the output.
+			if _, err := out.Write(frame.RawBytes); err != nil {
+				return fmt.Errorf("Failed to write a frame of %s. Error: %s", in.Name, err)
 			}
 
-			totalFrames += 1
-			totalBytes += uint32(len(frame.RawBytes))
+			header.add(frame)
+			input.Frames++
+			input.Bytes += int64(len(frame.RawBytes))
+			input.Duration += frameDuration(frame)
+			result.AddBitRate(frame.BitRate, 1)
+			return nil
 		}
 
-		infile.Close()
-		totalFiles += 1
-	}
-
-	outfile.Close()
-
-	// If we detected multiple bitrates, prepend a VBR header to the file.
-	if isVBR {
-		fmt.Println("• Multiple bitrates detected. Adding VBR header.")
-		addXingHeader(outpath, totalFrames, totalBytes)
-	}
-
-	// Copy the ID3v2 tag from the first input file if requested. Order of
-	// operations is important here. The ID3 tag must be the first item in
-	// the file - in particular, it must come *before* any VBR header.
-	if tag {
-		fmt.Println("• Adding ID3 tag.")
-		addID3v2Tag(outpath, inpaths[0])
-	}
-
-	// Print a count of the number of files merged.
-	fmt.Printf("• %v files merged.\n", totalFiles)
-}
-
-// Prepend an Xing VBR header to the specified MP3 file.
-func addXingHeader(filepath string, totalFrames, totalBytes uint32) {
-
-	outputFile, err := os.Create(filepath + ".mp3cat.tmp")
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-
-	inputFile, err := os.Open(filepath)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+		scanner := newScanner(in.Reader)
+		for objects := 0; ; objects++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 
-	xingHeader := mp3lib.NewXingHeader(totalFrames, totalBytes)
+			// Read the next frame or tag from the input.
+			obj, offset, skipped := scanner.next()
+			if len(skipped) > 0 {
+				start := offset - int64(len(skipped))
+				switch {
+				case isAPETag(skipped):
+					problem(start, "Stripped an APE tag of %d bytes", len(skipped))
+				case obj == nil:
+					problem(start, "Dropped %d bytes at the end, a truncated frame or junk", len(skipped))
+				case pending != nil && truncated(pending, skipped):
+					problem(pendingOffset, "Dropped a truncated frame, the %d bytes after it are the rest of the next frame", len(skipped))
+					pending = nil
+				default:
+					problem(start, "Skipped %d bytes of junk", len(skipped))
+				}
+			}
+			if err := write(); err != nil {
+				return nil, err
+			}
+			if obj == nil {
+				break
+			}
 
-	_, err = outputFile.Write(xingHeader.RawBytes)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+			frame, ok := obj.(*mp3lib.MP3Frame)
+			if !ok {
+				switch tag := obj.(type) {
+				case *mp3lib.ID3v2Tag:
+					if objects > 0 {
+						problem(offset, "Stripped an ID3v2 tag of %d bytes after the start", len(tag.RawBytes))
+						continue
+					}
+					// Copy the ID3v2 tag of the first input if requested.
+					// The tag must be the first item in the output, before
+					// the VBR header.
+					if opts.Tag && len(opts.Header) == 0 && i == 0 {
+						if _, err := out.Write(tag.RawBytes); err != nil {
+							return nil, fmt.Errorf("Failed to write the ID3v2 tag. Error: %s", err)
+						}
+					}
+				case *mp3lib.ID3v1Tag:
+					problem(offset, "Stripped an ID3v1 tag")
+				}
+				continue
+			}
 
-	_, err = io.Copy(outputFile, inputFile)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+			// Skip the first frame if it's a VBR header. The output keeps
+			// the encoder delay of the first input and the padding of the
+			// last input.
+			if isFirstFrame {
+				isFirstFrame = false
+				if d, p, ok := gapless(frame); ok {
+					if i == 0 {
+						delay = d
+					}
+					if i == len(inputs)-1 {
+						padding = p
+					}
+				}
+				if mp3lib.IsXingHeader(frame) || mp3lib.IsVbriHeader(frame) {
+					continue
+				}
+			}
 
-	outputFile.Close()
-	inputFile.Close()
+			// All frames must have the format of the first frame. An input
+			// in another format cannot be repaired.
+			if stream == (format{}) {
+				stream = formatOf(frame)
+			} else if f := formatOf(frame); f != stream {
+				if input.Frames == 0 && pending == nil {
+					return nil, append(report, Problem{Input: in.Name, Offset: offset, Message: fmt.Sprintf("Has %v frames, the stream is %v", f, stream)})
+				}
+				problem(offset, "Dropped a %v frame in a %v stream", f, stream)
+				continue
+			}
+			pending, pendingOffset = frame, offset
+		}
 
-	err = os.Remove(filepath)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		result.Add(input)
+		if opts.Progress != nil {
+			opts.Progress(Progress{Input: i, Inputs: len(inputs), Name: in.Name, Frames: result.Frames})
+		}
 	}
-
-	err = os.Rename(filepath+".mp3cat.tmp", filepath)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	if len(report) > 0 && !opts.Repair {
+		return nil, report
 	}
-}
-
-// Prepend an ID3v2 tag to the MP3 file at mp3Path, copying from tagPath.
-func addID3v2Tag(mp3Path, tagPath string) {
 
-	tagFile, err := os.Open(tagPath)
+	end, err := out.Seek(0, io.SeekCurrent)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return nil, err
 	}
-
-	id3tag := mp3lib.NextID3v2Tag(tagFile)
-	tagFile.Close()
-
-	if id3tag != nil {
-		outputFile, err := os.Create(mp3Path + ".mp3cat.tmp")
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+	if header != nil {
+		header.delay, header.padding = delay, padding
+		if _, err := out.Seek(headerOffset, io.SeekStart); err != nil {
+			return nil, err
 		}
-
-		inputFile, err := os.Open(mp3Path)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+		if _, err := out.Write(header.frame()); err != nil {
+			return nil, fmt.Errorf("Failed to write the VBR header. Error: %s", err)
 		}
-
-		_, err = outputFile.Write(id3tag.RawBytes)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+		if _, err := out.Seek(end, io.SeekStart); err != nil {
+			return nil, err
 		}
+	}
+	result.Bytes = end
+	return result, nil
+}
 
-		_, err = io.Copy(outputFile, inputFile)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+// Duration returns the playing time of the MP3 frames in the stream, not
+// counting a VBR header frame.
+func Duration(stream io.Reader) time.Duration {
+	var duration time.Duration
+	isFirstFrame := true
+	for {
+		frame := mp3lib.NextFrame(stream)
+		if frame == nil {
+			return duration
 		}
-
-		outputFile.Close()
-		inputFile.Close()
-
-		err = os.Remove(mp3Path)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+		if isFirstFrame {
+			isFirstFrame = false
+			if mp3lib.IsXingHeader(frame) || mp3lib.IsVbriHeader(frame) {
+				continue
+			}
 		}
+		duration += frameDuration(frame)
+	}
+}
 
-		err = os.Rename(mp3Path+".mp3cat.tmp", mp3Path)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
+// frameDuration returns the playing time of a single frame.
+func frameDuration(frame *mp3lib.MP3Frame) time.Duration {
+	if frame.SamplingRate == 0 {
+		return 0
 	}
+	return time.Duration(frame.SampleCount) * time.Second / time.Duration(frame.SamplingRate)
 }
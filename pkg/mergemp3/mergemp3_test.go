@@ -0,0 +1,158 @@
+package mergemp3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3/mp3test"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFrames(t *testing.T, path string, n int, bitRateIndex byte) {
+	assert.Nil(t, ioutil.WriteFile(path, bytes.Repeat(mp3test.Frame(bitRateIndex), n), 0644))
+}
+
+func TestMergeResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	first, second, out := filepath.Join(dir, "0.mp3"), filepath.Join(dir, "1.mp3"), filepath.Join(dir, "out.mp3")
+	writeFrames(t, first, 10, 9)
+	writeFrames(t, second, 5, 5)
+
+	var progress []Progress
+	result, err := Merge(context.Background(), out, []string{first, second}, Options{Progress: func(p Progress) { progress = append(progress, p) }})
+	assert.Nil(t, err)
+	assert.Equal(t, []Progress{{Input: 0, Inputs: 2, Name: first, Frames: 10}, {Input: 1, Inputs: 2, Name: second, Frames: 15}}, progress)
+	frameTime := 1152 * time.Second / 44100
+	assert.Equal(t, 15, result.Frames)
+	assert.Equal(t, 2, len(result.Inputs))
+	assert.Equal(t, 10*frameTime, result.Inputs[1].Start)
+	assert.Equal(t, 5*frameTime, result.Inputs[1].Duration)
+	assert.Equal(t, 15*frameTime, result.Duration)
+	assert.Equal(t, map[int]int{128000: 10, 64000: 5}, result.BitRates)
+	assert.Equal(t, int64(5*len(mp3test.Frame(5))), result.Inputs[1].Bytes)
+	info, err := os.Stat(out)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Size(), result.Bytes)
+
+	f, err := os.Open(out)
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Equal(t, 15*frameTime, Duration(f))
+}
+
+func TestLazyFileIsOpenOnlyWhileRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "0.mp3")
+	writeFrames(t, path, 3, 9)
+
+	f := &lazyFile{path: path}
+	assert.Nil(t, f.file)
+	content, err := ioutil.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, 3*len(mp3test.Frame(9)), len(content))
+	assert.Nil(t, f.file)
+	_, err = f.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	_, err = Merge(context.Background(), filepath.Join(dir, "out.mp3"), []string{path, filepath.Join(dir, "missing.mp3")}, Options{})
+	assert.NotNil(t, err)
+}
+
+// buffer is an in-memory io.WriteSeeker.
+type buffer struct {
+	data []byte
+	pos  int
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	if end := b.pos + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	copy(b.data[b.pos:], p)
+	b.pos += len(p)
+	return len(p), nil
+}
+
+func (b *buffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(b.pos)
+	case io.SeekEnd:
+		offset += int64(len(b.data))
+	}
+	b.pos = int(offset)
+	return offset, nil
+}
+
+func TestMergeStreams(t *testing.T) {
+	tag := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x0A"), make([]byte, 10)...)
+	first := bytes.NewReader(append(tag, bytes.Repeat(mp3test.Frame(9), 3)...))
+	second := bytes.NewReader(bytes.Repeat(mp3test.Frame(5), 2))
+
+	out := &buffer{}
+	result, err := MergeStreams(context.Background(), out, []Input{{Name: "first", Reader: first}, {Name: "second", Reader: second}}, Options{Tag: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, result.Frames)
+	assert.Equal(t, int64(len(out.data)), result.Bytes)
+	assert.Equal(t, tag, out.data[:len(tag)])
+
+	// The Xing header follows the tag and counts the frames and bytes.
+	header := out.data[len(tag):]
+	offset := 4 + 32
+	assert.Equal(t, "Xing", string(header[offset:offset+4]))
+	assert.Equal(t, []byte{0, 0, 0, 5}, header[offset+8:offset+12])
+	// The header is a 64 kbps frame, the lowest bitrate that fits it.
+	assert.Equal(t, 208, len(out.data)-len(tag)-3*len(mp3test.Frame(9))-2*len(mp3test.Frame(5)))
+}
+
+func TestMergeStreamsWithHeader(t *testing.T) {
+	tag := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x0A"), make([]byte, 10)...)
+	header := []byte("ID3\x04\x00\x00\x00\x00\x00\x00")
+	out := &buffer{}
+	result, err := MergeStreams(context.Background(), out, []Input{{Name: "first", Reader: bytes.NewReader(append(tag, mp3test.Frame(9)...))}}, Options{Tag: true, Header: header})
+	assert.Nil(t, err)
+	assert.Equal(t, header, out.data[:len(header)])
+	assert.Equal(t, "Info", string(out.data[len(header)+36:len(header)+40]))
+	assert.Equal(t, 1, result.Frames)
+}
+
+func TestMergeStreamsSingleBitRate(t *testing.T) {
+	out := &buffer{}
+	_, err := MergeStreams(context.Background(), out, []Input{{Name: "only", Reader: bytes.NewReader(bytes.Repeat(mp3test.Frame(9), 4))}}, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Info", string(out.data[36:40]))
+	assert.Equal(t, 4*(1152*time.Second/44100), Duration(bytes.NewReader(out.data)))
+}
+
+func TestMergeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := MergeStreams(ctx, &buffer{}, []Input{{Name: "only", Reader: bytes.NewReader(mp3test.Frame(9))}}, Options{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestMergeExistingOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "0.mp3"), filepath.Join(dir, "out.mp3")
+	writeFrames(t, in, 2, 9)
+	assert.Nil(t, ioutil.WriteFile(out, []byte("existing"), 0644))
+
+	_, err = Merge(context.Background(), out, []string{in}, Options{})
+	assert.NotNil(t, err)
+	_, err = Merge(context.Background(), in, []string{in}, Options{Force: true})
+	assert.NotNil(t, err)
+	_, err = Merge(context.Background(), out, []string{in}, Options{Force: true})
+	assert.Nil(t, err)
+}
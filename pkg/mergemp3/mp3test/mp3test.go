@@ -0,0 +1,12 @@
+// Package mp3test builds MP3 frames for the tests of the packages that read
+// and merge MP3 streams.
+package mp3test
+
+// Frame returns a silent MPEG-1 layer III frame at 44.1 kHz with the given
+// bitrate index, 9 is 128 kbps and 5 is 64 kbps.
+func Frame(bitRateIndex byte) []byte {
+	bitRates := map[byte]int{5: 64000, 9: 128000}
+	f := make([]byte, 144*bitRates[bitRateIndex]/44100)
+	copy(f, []byte{0xFF, 0xFB, bitRateIndex << 4, 0x00})
+	return f
+}
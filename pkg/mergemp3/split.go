@@ -0,0 +1,221 @@
+package mergemp3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/audiostats"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/id3"
+	"github.com/dmulholland/mp3lib"
+)
+
+// Part is a span of an MP3 file to cut out to Path.
+type Part struct {
+	Path  string
+	Start time.Duration
+	// End is where the part ends, zero means the end of the file.
+	End time.Duration
+	// Title replaces the title of the tag, when set.
+	Title string
+}
+
+// SplitAt returns the parts between the cuts, which are in order.
+func SplitAt(cuts []time.Duration) []Part {
+	parts := []Part{{}}
+	for _, cut := range cuts {
+		parts[len(parts)-1].End = cut
+		parts = append(parts, Part{Start: cut})
+	}
+	return parts
+}
+
+// SplitChapters returns a part for every chapter, with its title.
+func SplitChapters(chapters []id3.Chapter) []Part {
+	var parts []Part
+	for _, c := range chapters {
+		parts = append(parts, Part{Start: c.Start, End: c.End, Title: c.Title})
+	}
+	return parts
+}
+
+// SplitEqual returns n parts of equal length of a file with the given
+// duration.
+func SplitEqual(duration time.Duration, n int) []Part {
+	var cuts []time.Duration
+	for i := 1; i < n; i++ {
+		cuts = append(cuts, duration*time.Duration(i)/time.Duration(n))
+	}
+	return SplitAt(cuts)
+}
+
+// frameIndex holds the offsets and start times of the audio frames of a
+// file, and the offset where the last frame ends. delay and padding are the
+// encoder delay and padding in the LAME tag of the file.
+type frameIndex struct {
+	offsets []int64
+	starts  []time.Duration
+	end     int64
+	length  time.Duration
+	delay   int
+	padding int
+}
+
+func indexFrames(ctx context.Context, r io.Reader) (*frameIndex, error) {
+	index := &frameIndex{}
+	s := newScanner(r)
+	for isFirstFrame := true; ; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		obj, offset, _ := s.next()
+		if obj == nil {
+			break
+		}
+		frame, ok := obj.(*mp3lib.MP3Frame)
+		if !ok {
+			continue
+		}
+		if isFirstFrame {
+			isFirstFrame = false
+			index.delay, index.padding, _ = gapless(frame)
+			if mp3lib.IsXingHeader(frame) || mp3lib.IsVbriHeader(frame) {
+				continue
+			}
+		}
+		index.offsets = append(index.offsets, offset)
+		index.starts = append(index.starts, index.length)
+		index.length += frameDuration(frame)
+		index.end = offset + int64(len(frame.RawBytes))
+	}
+	if len(index.offsets) == 0 {
+		return nil, errors.New("No MP3 frames found")
+	}
+	return index, nil
+}
+
+// nearest returns the index of the frame boundary nearest to t, the number
+// of frames for the end of the file.
+func (index *frameIndex) nearest(t time.Duration) int {
+	if t <= 0 {
+		return 0
+	}
+	for i, start := range index.starts {
+		if start >= t {
+			if i > 0 && t-index.starts[i-1] < start-t {
+				return i - 1
+			}
+			return i
+		}
+	}
+	if t-index.starts[len(index.starts)-1] < index.length-t {
+		return len(index.starts) - 1
+	}
+	return len(index.starts)
+}
+
+// offset returns the offset of the frame boundary before frame i.
+func (index *frameIndex) offset(i int) int64 {
+	if i >= len(index.offsets) {
+		return index.end
+	}
+	return index.offsets[i]
+}
+
+// Split cuts the MP3 file at inpath into parts at the frame boundaries
+// nearest to their start and end, without decoding. Every part gets a Xing
+// header and a copy of the ID3v2 tag of the file, with the chapters that
+// fall in the part. The part at the start of the file keeps its encoder
+// delay and the part at the end its padding. The parts are always merged
+// with Repair, they leave out the junk that the frame index skipped instead
+// of failing on it. Returns the statistics of every part.
+func Split(ctx context.Context, inpath string, parts []Part, opts Options) ([]*audiostats.Result, error) {
+	in, err := os.Open(inpath)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	tag, err := id3.Read(in)
+	if err != nil && err != id3.ErrNoTag {
+		return nil, err
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	index, err := indexFrames(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s. Error: %s", inpath, err)
+	}
+
+	var results []*audiostats.Result
+	for _, p := range parts {
+		if p.Path == inpath {
+			return results, errors.New("A part would overwrite the input file " + inpath)
+		}
+		if _, err := os.Stat(p.Path); err == nil && !opts.Force {
+			return results, fmt.Errorf("The file '%v' already exists", p.Path)
+		}
+		first, last := index.nearest(p.Start), len(index.offsets)
+		if p.End > 0 {
+			last = index.nearest(p.End)
+		}
+		if last <= first {
+			return results, fmt.Errorf("The part %v of %v to %v has no frames", p.Path, p.Start, p.End)
+		}
+		start, end := index.starts[first], index.length
+		if last < len(index.starts) {
+			end = index.starts[last]
+		}
+		partOpts := opts
+		partOpts.Tag = false
+		partOpts.Header = nil
+		partOpts.Repair = true
+		if tag != nil {
+			if partOpts.Header, err = partTag(tag, p.Title, start, end).Bytes(); err != nil {
+				return results, err
+			}
+		}
+		input := Input{Name: inpath, Reader: io.NewSectionReader(in, index.offset(first), index.offset(last)-index.offset(first))}
+		if first == 0 {
+			input.Delay = index.delay
+		}
+		if last == len(index.offsets) {
+			input.Padding = index.padding
+		}
+		result, err := mergeFile(ctx, p.Path, []Input{input}, partOpts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// partTag returns a copy of tag for the part from start to end, with the
+// chapters that overlap it moved to its start.
+func partTag(tag *id3.Tag, title string, start, end time.Duration) *id3.Tag {
+	part := *tag
+	part.Padding = 0
+	part.Chapters = nil
+	if len(title) > 0 {
+		part.Title = title
+	}
+	for _, c := range tag.Chapters {
+		if c.End <= start || c.Start >= end {
+			continue
+		}
+		if c.Start < start {
+			c.Start = start
+		}
+		if c.End > end {
+			c.End = end
+		}
+		c.Start -= start
+		c.End -= start
+		part.Chapters = append(part.Chapters, c)
+	}
+	return &part
+}
@@ -0,0 +1,123 @@
+package mergemp3
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/id3"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3/mp3test"
+	"github.com/dmulholland/mp3lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitChapters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in, episode := filepath.Join(dir, "in.mp3"), filepath.Join(dir, "episode.mp3")
+	writeFrames(t, in, 100, 9)
+	_, err = Merge(context.Background(), episode, []string{in}, Options{})
+	assert.Nil(t, err)
+	frameTime := 1152 * time.Second / 44100
+	tag := &id3.Tag{Title: "Episode", Artist: "Hacker News", Chapters: []id3.Chapter{
+		{Title: "Introduction", Start: 0, End: 30 * frameTime},
+		{Title: "First story", Start: 30 * frameTime, End: 100 * frameTime},
+	}}
+	assert.Nil(t, id3.WriteFile(episode, tag))
+
+	parts := SplitChapters(tag.Chapters)
+	parts[0].Path, parts[1].Path = filepath.Join(dir, "0.mp3"), filepath.Join(dir, "1.mp3")
+	results, err := Split(context.Background(), episode, parts, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 30, results[0].Frames)
+	assert.Equal(t, 70, results[1].Frames)
+
+	part, err := id3.ReadFile(parts[1].Path)
+	assert.Nil(t, err)
+	assert.Equal(t, "First story", part.Title)
+	assert.Equal(t, "Hacker News", part.Artist)
+	assert.Equal(t, []id3.Chapter{{Title: "First story", Start: 0, End: 70 * frameTime / time.Millisecond * time.Millisecond}}, part.Chapters)
+
+	content, err := ioutil.ReadFile(parts[1].Path)
+	assert.Nil(t, err)
+	header, ok := ReadVBRHeader(mp3lib.NextFrame(bytes.NewReader(content[id3.TagSize(content):])))
+	assert.True(t, ok)
+	assert.Equal(t, "Info", header.ID)
+	assert.Equal(t, uint32(70), header.Frames)
+	assert.Equal(t, 70*frameTime, Duration(bytes.NewReader(content)))
+}
+
+func TestSplitAtNearestFrame(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in.mp3")
+	writeFrames(t, in, 10, 9)
+	frameTime := 1152 * time.Second / 44100
+
+	parts := SplitAt([]time.Duration{3*frameTime + frameTime/3, 7*frameTime - frameTime/3})
+	for i := range parts {
+		parts[i].Path = filepath.Join(dir, string('a'+rune(i))+".mp3")
+	}
+	results, err := Split(context.Background(), in, parts, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, results[0].Frames)
+	assert.Equal(t, 4, results[1].Frames)
+	assert.Equal(t, 3, results[2].Frames)
+
+	// The input has no tag, neither have the parts.
+	_, err = id3.ReadFile(parts[0].Path)
+	assert.Equal(t, id3.ErrNoTag, err)
+
+	_, err = Split(context.Background(), in, []Part{{Path: filepath.Join(dir, "empty.mp3"), Start: frameTime, End: frameTime}}, Options{})
+	assert.NotNil(t, err)
+	_, err = Split(context.Background(), in, parts[:1], Options{})
+	assert.NotNil(t, err)
+}
+
+func TestSplitKeepsTheGaplessInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	h := newVBRHeader(mp3lib.NextFrame(bytes.NewReader(mp3test.Frame(9))))
+	h.delay, h.padding = 576, 1000
+	in := filepath.Join(dir, "in.mp3")
+	assert.Nil(t, ioutil.WriteFile(in, append(h.frame(), bytes.Repeat(mp3test.Frame(9), 10)...), 0644))
+	frameTime := 1152 * time.Second / 44100
+
+	parts := SplitAt([]time.Duration{5 * frameTime})
+	parts[0].Path, parts[1].Path = filepath.Join(dir, "0.mp3"), filepath.Join(dir, "1.mp3")
+	_, err = Split(context.Background(), in, parts, Options{})
+	assert.Nil(t, err)
+	for i, want := range [][2]int{{576, 0}, {0, 1000}} {
+		content, err := ioutil.ReadFile(parts[i].Path)
+		assert.Nil(t, err)
+		header, ok := ReadVBRHeader(mp3lib.NextFrame(bytes.NewReader(content)))
+		assert.True(t, ok)
+		assert.Equal(t, want, [2]int{header.Delay, header.Padding})
+	}
+}
+
+func TestSplitSkipsJunkBetweenFrames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mergemp3")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in.mp3")
+	content := append(bytes.Repeat(mp3test.Frame(9), 3), 0x00, 0x00)
+	assert.Nil(t, ioutil.WriteFile(in, append(content, bytes.Repeat(mp3test.Frame(9), 3)...), 0644))
+
+	var problems []Problem
+	results, err := Split(context.Background(), in, []Part{{Path: filepath.Join(dir, "0.mp3")}}, Options{Problem: func(p Problem) { problems = append(problems, p) }})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, results[0].Frames)
+	assert.Equal(t, 1, len(problems))
+}
+
+func TestSplitEqual(t *testing.T) {
+	assert.Equal(t, []Part{{End: 20 * time.Minute}, {Start: 20 * time.Minute, End: 40 * time.Minute}, {Start: 40 * time.Minute}}, SplitEqual(time.Hour, 3))
+}
@@ -0,0 +1,119 @@
+package mergemp3
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dmulholland/mp3lib"
+)
+
+// Problem is a defect in an input stream.
+type Problem struct {
+	Input string
+	// Offset is the position in the input where the defect starts.
+	Offset  int64
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s at byte %d: %s", p.Input, p.Offset, p.Message)
+}
+
+// Report is the error of a merge that found defects in the inputs without
+// the Repair option.
+type Report []Problem
+
+func (r Report) Error() string {
+	lines := []string{fmt.Sprintf("Found %d problems in the MP3 inputs", len(r))}
+	for _, p := range r {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// format is what the frames of a stream must have in common to be played as
+// one stream. The channel mode only counts as mono or not, encoders switch
+// between stereo and joint stereo from frame to frame.
+type format struct {
+	version    byte
+	layer      byte
+	sampleRate int
+	mono       bool
+}
+
+func formatOf(frame *mp3lib.MP3Frame) format {
+	return format{frame.MPEGVersion, frame.MPEGLayer, frame.SamplingRate, frame.ChannelMode == mp3lib.Mono}
+}
+
+func (f format) String() string {
+	version := map[byte]string{mp3lib.MPEGVersion1: "1", mp3lib.MPEGVersion2: "2", mp3lib.MPEGVersion2_5: "2.5"}[f.version]
+	layer := map[byte]string{mp3lib.MPEGLayerI: "I", mp3lib.MPEGLayerII: "II", mp3lib.MPEGLayerIII: "III"}[f.layer]
+	channels := "stereo"
+	if f.mono {
+		channels = "mono"
+	}
+	return fmt.Sprintf("MPEG-%s layer %s %d Hz %s", version, layer, f.sampleRate, channels)
+}
+
+// scanner reads the frames and tags of a stream, keeping the bytes that are
+// skipped before them.
+type scanner struct {
+	r        *bufio.Reader
+	position int64
+	consumed []byte
+}
+
+func newScanner(r io.Reader) *scanner {
+	return &scanner{r: bufio.NewReader(r)}
+}
+
+func (s *scanner) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.position += int64(n)
+	s.consumed = append(s.consumed, p[:n]...)
+	return n, err
+}
+
+// next returns the next frame or tag with its offset, and the bytes before
+// it that are not part of any. At the end of the stream obj is nil and skipped
+// holds the bytes after the last object.
+func (s *scanner) next() (obj interface{}, offset int64, skipped []byte) {
+	s.consumed = s.consumed[:0]
+	obj = mp3lib.NextObject(s)
+	size := 0
+	switch obj := obj.(type) {
+	case *mp3lib.MP3Frame:
+		size = len(obj.RawBytes)
+	case *mp3lib.ID3v1Tag:
+		size = len(obj.RawBytes)
+	case *mp3lib.ID3v2Tag:
+		size = len(obj.RawBytes)
+	}
+	skipped = s.consumed[:len(s.consumed)-size]
+	return obj, s.position - int64(size), skipped
+}
+
+// isAPETag reports whether skipped bytes hold an APE tag.
+func isAPETag(skipped []byte) bool {
+	return bytes.Contains(skipped, []byte("APETAGEX"))
+}
+
+// truncated reports whether a frame is cut short by the frame after it. The
+// next frame then starts inside of the frame and ends in the skipped bytes.
+func truncated(frame *mp3lib.MP3Frame, skipped []byte) bool {
+	raw := frame.RawBytes
+	for p := 4; p+4 <= len(raw); p++ {
+		if raw[p] != 0xFF || raw[p+1]&0xE0 != 0xE0 {
+			continue
+		}
+		rest := append(append([]byte{}, raw[p:]...), skipped...)
+		next := mp3lib.NextFrame(bytes.NewReader(rest))
+		if next != nil && len(next.RawBytes) == len(rest) && bytes.Equal(next.RawBytes[:4], raw[p:p+4]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package mergemp3
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3/mp3test"
+	"github.com/stretchr/testify/assert"
+)
+
+// frame48 returns a 128 kbps frame at 48 kHz.
+func frame48() []byte {
+	f := make([]byte, 144*128000/48000)
+	copy(f, []byte{0xFF, 0xFB, 0x94, 0x00})
+	return f
+}
+
+func TestRepair(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(bytes.Repeat(mp3test.Frame(9), 2))
+	stream.WriteString("junk")
+	stream.Write(mp3test.Frame(9))
+	// A frame cut short, the next frame starts inside of it.
+	stream.Write(mp3test.Frame(9)[:100])
+	stream.Write(bytes.Repeat(mp3test.Frame(9), 2))
+	// A 48 kHz frame.
+	stream.Write(frame48())
+	stream.Write(mp3test.Frame(9))
+	stream.WriteString("TAG")
+	stream.Write(make([]byte, 125))
+
+	var problems []Problem
+	out := &buffer{}
+	result, err := MergeStreams(context.Background(), out, []Input{{Name: "in", Reader: &stream}}, Options{Repair: true, Problem: func(p Problem) { problems = append(problems, p) }})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, result.Frames)
+	n := len(mp3test.Frame(9))
+	assert.Equal(t, []Problem{
+		{Input: "in", Offset: int64(2 * n), Message: "Skipped 4 bytes of junk"},
+		{Input: "in", Offset: int64(3*n + 4), Message: "Dropped a truncated frame, the 100 bytes after it are the rest of the next frame"},
+		{Input: "in", Offset: int64(5*n + 104), Message: "Dropped a MPEG-1 layer III 48000 Hz stereo frame in a MPEG-1 layer III 44100 Hz stereo stream"},
+		{Input: "in", Offset: int64(6*n + 104 + 384), Message: "Stripped an ID3v1 tag"},
+	}, problems)
+}
+
+func TestReport(t *testing.T) {
+	stream := append(mp3test.Frame(9), "junk"...)
+	stream = append(stream, mp3test.Frame(9)...)
+	stream = append(stream, mp3test.Frame(9)[:50]...)
+	_, err := MergeStreams(context.Background(), &buffer{}, []Input{{Name: "in", Reader: bytes.NewReader(stream)}}, Options{})
+	report, ok := err.(Report)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(report))
+	assert.Equal(t, "Found 2 problems in the MP3 inputs\nin at byte 417: Skipped 4 bytes of junk\nin at byte 838: Dropped 50 bytes at the end, a truncated frame or junk", err.Error())
+}
+
+func TestMixedSampleRates(t *testing.T) {
+	_, err := MergeStreams(context.Background(), &buffer{}, []Input{
+		{Name: "speech", Reader: bytes.NewReader(mp3test.Frame(9))},
+		{Name: "jingle", Reader: bytes.NewReader(frame48())},
+	}, Options{Repair: true})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "jingle at byte 0: Has MPEG-1 layer III 48000 Hz stereo frames, the stream is MPEG-1 layer III 44100 Hz stereo")
+}
@@ -0,0 +1,251 @@
+package mergemp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/dmulholland/mp3lib"
+)
+
+// Sizes of the parts of a Xing header with all fields present, followed by
+// a LAME tag.
+const (
+	xingSize = 4 + 4 + 4 + 4 + 100 + 4
+	lameSize = 36
+)
+
+// lameVersion identifies the LAME tag, players only read the gapless info
+// of tags that start with LAME.
+const lameVersion = "LAME3.100"
+
+// layer3BitRates are the bitrates in kbps of MPEG-1 and of MPEG-2 and 2.5
+// layer III frames, by bitrate index.
+var layer3BitRates = map[bool][]int{
+	true:  {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	false: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// vbrHeader collects the frames of a stream for the Xing header and LAME
+// tag in the frame before them.
+type vbrHeader struct {
+	// template is the first frame of the stream, the header frame has its
+	// MPEG version, sample rate and channel mode.
+	template *mp3lib.MP3Frame
+	// offsets are the offsets of the frames after the header frame.
+	offsets []uint32
+	bytes   uint32
+	vbr     bool
+	// crc is the CRC-16 of the frames after the header frame.
+	crc uint16
+	// delay and padding are the samples that the encoder added at the start
+	// and the end of the stream.
+	delay, padding int
+}
+
+func newVBRHeader(template *mp3lib.MP3Frame) *vbrHeader {
+	return &vbrHeader{template: template}
+}
+
+// add counts a frame that is written after the header frame.
+func (h *vbrHeader) add(frame *mp3lib.MP3Frame) {
+	h.offsets = append(h.offsets, h.bytes)
+	h.bytes += uint32(len(frame.RawBytes))
+	if frame.BitRate != h.template.BitRate {
+		h.vbr = true
+	}
+	h.crc = crc16(h.crc, frame.RawBytes)
+}
+
+// sideInfoSize returns the size of the side information block after the
+// frame header, where the Xing header begins.
+func sideInfoSize(frame *mp3lib.MP3Frame) int {
+	mpeg1 := frame.MPEGVersion == mp3lib.MPEGVersion1
+	switch {
+	case mpeg1 && frame.ChannelMode != mp3lib.Mono:
+		return 32
+	case !mpeg1 && frame.ChannelMode == mp3lib.Mono:
+		return 9
+	}
+	return 17
+}
+
+// frame returns the header frame: a Xing header with the number of frames
+// and bytes, a seek table and a LAME tag with the encoder delay and padding.
+// The header of a stream with a single bitrate is marked Info instead of
+// Xing. The length of the frame does not depend on the counts, so that it
+// can be written before the frames and filled in afterwards.
+func (h *vbrHeader) frame() []byte {
+	if h.template.MPEGLayer != mp3lib.MPEGLayerIII || h.template.SamplingRate == 0 {
+		return mp3lib.NewXingHeader(uint32(len(h.offsets)), h.bytes).RawBytes
+	}
+	offset := 4 + sideInfoSize(h.template)
+	mpeg1 := h.template.MPEGVersion == mp3lib.MPEGVersion1
+	slots := 144
+	if !mpeg1 {
+		slots = 72
+	}
+
+	// Use the lowest bitrate with a frame that fits the header.
+	index, length := 1, 0
+	for ; index < 15; index++ {
+		length = slots * layer3BitRates[mpeg1][index] * 1000 / h.template.SamplingRate
+		if length >= offset+xingSize+lameSize {
+			break
+		}
+	}
+
+	raw := make([]byte, length)
+	copy(raw, h.template.RawBytes[:4])
+	// No CRC, no padding and the chosen bitrate.
+	raw[1] |= 0x01
+	raw[2] = byte(index)<<4 | raw[2]&0x0C
+
+	// The byte count and the seek table include the header frame.
+	total := uint32(length) + h.bytes
+	xing := raw[offset : offset+xingSize]
+	if h.vbr {
+		copy(xing, "Xing")
+	} else {
+		copy(xing, "Info")
+	}
+	// The frames, bytes, seek table and quality fields are present.
+	binary.BigEndian.PutUint32(xing[4:8], 0x0F)
+	binary.BigEndian.PutUint32(xing[8:12], uint32(len(h.offsets)))
+	binary.BigEndian.PutUint32(xing[12:16], total)
+	// Entry i of the seek table is the position in the stream at i percent
+	// of the playing time, in 256ths of the byte count.
+	for i := 0; i < 100; i++ {
+		var position uint32
+		if n := len(h.offsets); n > 0 {
+			position = uint32(length) + h.offsets[i*n/100]
+		}
+		xing[16+i] = byte(uint64(position) * 256 / uint64(total))
+	}
+
+	lame := raw[offset+xingSize : offset+xingSize+lameSize]
+	copy(lame, lameVersion)
+	if !h.vbr {
+		// The VBR method is CBR.
+		lame[9] = 1
+	}
+	lame[21] = byte(h.delay >> 4)
+	lame[22] = byte(h.delay<<4) | byte(h.padding>>8&0x0F)
+	lame[23] = byte(h.padding)
+	binary.BigEndian.PutUint32(lame[28:32], total)
+	binary.BigEndian.PutUint16(lame[32:34], h.crc)
+	binary.BigEndian.PutUint16(lame[34:36], crc16(0, raw[:offset+xingSize+34]))
+	return raw
+}
+
+// VBRHeader is the content of a Xing or Info header frame and of the LAME
+// tag after it. Fields that are not present are zero.
+type VBRHeader struct {
+	// ID is Xing or Info, the header of a stream with a single bitrate.
+	ID     string `json:"id"`
+	Frames uint32 `json:"frames"`
+	Bytes  uint32 `json:"bytes"`
+	// TOC is the seek table, entry i is the position at i percent of the
+	// playing time in 256ths of Bytes.
+	TOC     []byte `json:"-"`
+	Quality uint32 `json:"quality"`
+	// Encoder is the version in the LAME tag, like LAME3.100.
+	Encoder string `json:"encoder,omitempty"`
+	// Delay and Padding are the samples that the encoder added at the start
+	// and the end of the stream.
+	Delay   int `json:"delay"`
+	Padding int `json:"padding"`
+}
+
+// MarshalJSON formats the header as json, with the TOC as a list of numbers
+// instead of base64.
+func (h *VBRHeader) MarshalJSON() ([]byte, error) {
+	type plain VBRHeader
+	out := struct {
+		*plain
+		TOC []int `json:"toc,omitempty"`
+	}{plain: (*plain)(h)}
+	for _, position := range h.TOC {
+		out.TOC = append(out.TOC, int(position))
+	}
+	return json.Marshal(out)
+}
+
+// ReadVBRHeader reads the Xing header in a frame, ok is false when the frame
+// is not a header frame.
+func ReadVBRHeader(frame *mp3lib.MP3Frame) (header *VBRHeader, ok bool) {
+	if !mp3lib.IsXingHeader(frame) {
+		return nil, false
+	}
+	raw := frame.RawBytes
+	offset := 4 + sideInfoSize(frame)
+	if len(raw) < offset+8 {
+		return nil, false
+	}
+	header = &VBRHeader{ID: string(raw[offset : offset+4])}
+	flags := binary.BigEndian.Uint32(raw[offset+4 : offset+8])
+	offset += 8
+	field := func(flag uint32, size int) []byte {
+		if flags&flag == 0 || len(raw) < offset+size {
+			return nil
+		}
+		offset += size
+		return raw[offset-size : offset]
+	}
+	if b := field(1, 4); b != nil {
+		header.Frames = binary.BigEndian.Uint32(b)
+	}
+	if b := field(2, 4); b != nil {
+		header.Bytes = binary.BigEndian.Uint32(b)
+	}
+	header.TOC = field(4, 100)
+	if b := field(8, 4); b != nil {
+		header.Quality = binary.BigEndian.Uint32(b)
+	}
+	if len(raw) < offset+24 {
+		return header, true
+	}
+	switch string(raw[offset : offset+4]) {
+	case "LAME", "Lavf", "Lavc":
+	default:
+		return header, true
+	}
+	lame := raw[offset:]
+	header.Encoder = string(bytes.TrimRight(lame[:9], "\x00 "))
+	header.Delay = int(lame[21])<<4 | int(lame[22])>>4
+	header.Padding = int(lame[22]&0x0F)<<8 | int(lame[23])
+	return header, true
+}
+
+// gapless returns the encoder delay and padding in the LAME tag of a Xing
+// header frame.
+func gapless(frame *mp3lib.MP3Frame) (delay, padding int, ok bool) {
+	header, ok := ReadVBRHeader(frame)
+	if !ok || len(header.Encoder) == 0 {
+		return 0, 0, false
+	}
+	return header.Delay, header.Padding, true
+}
+
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// crc16 updates crc with data, using the CRC-16 of the LAME tag.
+func crc16(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc = crc>>8 ^ crc16Table[byte(crc)^b]
+	}
+	return crc
+}
@@ -0,0 +1,91 @@
+package mergemp3
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/mergemp3/mp3test"
+	"github.com/dmulholland/mp3lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16(t *testing.T) {
+	assert.Equal(t, uint16(0xBB3D), crc16(0, []byte("123456789")))
+}
+
+func TestSeekTableAndGapless(t *testing.T) {
+	// The first input starts with a header that carries its encoder delay,
+	// the last one with its padding.
+	first := newVBRHeader(mp3lib.NextFrame(bytes.NewReader(mp3test.Frame(9))))
+	first.delay = 576
+	second := newVBRHeader(mp3lib.NextFrame(bytes.NewReader(mp3test.Frame(5))))
+	second.padding = 1000
+	inputs := []Input{
+		{Name: "first", Reader: bytes.NewReader(append(first.frame(), bytes.Repeat(mp3test.Frame(9), 100)...))},
+		{Name: "second", Reader: bytes.NewReader(append(second.frame(), bytes.Repeat(mp3test.Frame(5), 100)...))},
+	}
+
+	out := &buffer{}
+	result, err := MergeStreams(context.Background(), out, inputs, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 200, result.Frames)
+
+	header := mp3lib.NextFrame(bytes.NewReader(out.data))
+	assert.True(t, mp3lib.IsXingHeader(header))
+	delay, padding, ok := gapless(header)
+	assert.True(t, ok)
+	assert.Equal(t, 576, delay)
+	assert.Equal(t, 1000, padding)
+
+	xing := header.RawBytes[36:]
+	assert.Equal(t, "Xing", string(xing[:4]))
+	assert.Equal(t, uint32(200), binary.BigEndian.Uint32(xing[8:12]))
+	assert.Equal(t, uint32(len(out.data)), binary.BigEndian.Uint32(xing[12:16]))
+	// Half of the playing time is at the end of the first input.
+	toc := xing[16:116]
+	half := len(header.RawBytes) + 100*len(mp3test.Frame(9))
+	assert.Equal(t, byte(half*256/len(out.data)), toc[50])
+	for i := 1; i < 100; i++ {
+		assert.True(t, toc[i] >= toc[i-1])
+	}
+
+	lame := xing[120:]
+	assert.Equal(t, lameVersion, string(lame[:9]))
+	assert.Equal(t, crc16(0, header.RawBytes[:36+120+34]), binary.BigEndian.Uint16(lame[34:36]))
+	assert.Equal(t, crc16(0, out.data[len(header.RawBytes):]), binary.BigEndian.Uint16(lame[32:34]))
+}
+
+func TestHeaderMatchesStream(t *testing.T) {
+	// An MPEG-2 mono frame at 24 kHz, like the speech of the API.
+	mono := make([]byte, 72*32000/24000)
+	copy(mono, []byte{0xFF, 0xF3, 0x44, 0xC0})
+	out := &buffer{}
+	_, err := MergeStreams(context.Background(), out, []Input{{Name: "mono", Reader: bytes.NewReader(bytes.Repeat(mono, 3))}}, Options{})
+	assert.Nil(t, err)
+	header := mp3lib.NextFrame(bytes.NewReader(out.data))
+	assert.Equal(t, byte(mp3lib.MPEGVersion2), header.MPEGVersion)
+	assert.Equal(t, 24000, header.SamplingRate)
+	assert.Equal(t, byte(mp3lib.Mono), header.ChannelMode)
+	assert.True(t, mp3lib.IsXingHeader(header))
+	assert.Equal(t, "Info", string(header.RawBytes[4+9:4+13]))
+}
+
+func TestReadVBRHeader(t *testing.T) {
+	h := newVBRHeader(mp3lib.NextFrame(bytes.NewReader(mp3test.Frame(9))))
+	h.add(mp3lib.NextFrame(bytes.NewReader(mp3test.Frame(5))))
+	h.delay, h.padding = 576, 1152
+	header, ok := ReadVBRHeader(mp3lib.NextFrame(bytes.NewReader(h.frame())))
+	assert.True(t, ok)
+	assert.Equal(t, "Xing", header.ID)
+	assert.Equal(t, uint32(1), header.Frames)
+	assert.Equal(t, uint32(len(h.frame())+len(mp3test.Frame(5))), header.Bytes)
+	assert.Equal(t, 100, len(header.TOC))
+	assert.Equal(t, lameVersion, header.Encoder)
+	assert.Equal(t, 576, header.Delay)
+	assert.Equal(t, 1152, header.Padding)
+
+	_, ok = ReadVBRHeader(mp3lib.NextFrame(bytes.NewReader(mp3test.Frame(9))))
+	assert.False(t, ok)
+}
@@ -0,0 +1,200 @@
+// Package ogg reads the stream information of Ogg Opus and Ogg Vorbis
+// files, like the opus renditions of the encoder.
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Stream describes the first logical stream of an Ogg file.
+type Stream struct {
+	// Codec is opus or vorbis.
+	Codec    string
+	Channels int
+	// SampleRate is the rate of the decoded audio, always 48 kHz for Opus.
+	SampleRate int
+	// InputSampleRate is the rate of the audio that was encoded to Opus.
+	InputSampleRate int
+	// PreSkip is the number of samples at the start that the decoder drops.
+	PreSkip  int
+	Duration time.Duration
+	Pages    int
+	// Bytes is the size of all pages of the stream.
+	Bytes int64
+	// Vendor and Comments are read from the comment header, the comments
+	// are formatted as NAME=value.
+	Vendor   string
+	Comments []string
+}
+
+// page is an Ogg page with the packet data of its segments.
+type page struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	segments   []byte
+	data       []byte
+	size       int
+}
+
+// readPage reads the next page from r, it returns io.EOF at the end.
+func readPage(r io.Reader) (*page, error) {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("The Ogg file ends in a page header")
+		}
+		return nil, err
+	}
+	if string(header[0:4]) != "OggS" {
+		return nil, errors.New("Not an Ogg page")
+	}
+	p := &page{
+		headerType: header[5],
+		granule:    int64(binary.LittleEndian.Uint64(header[6:14])),
+		serial:     binary.LittleEndian.Uint32(header[14:18]),
+		segments:   make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(r, p.segments); err != nil {
+		return nil, fmt.Errorf("Failed to read an Ogg segment table. Error: %s", err)
+	}
+	size := 0
+	for _, s := range p.segments {
+		size += int(s)
+	}
+	p.data = make([]byte, size)
+	if _, err := io.ReadFull(r, p.data); err != nil {
+		return nil, fmt.Errorf("Failed to read an Ogg page. Error: %s", err)
+	}
+	p.size = len(header) + len(p.segments) + size
+	return p, nil
+}
+
+// Read reads the stream information of the first logical stream in r.
+func Read(r io.Reader) (*Stream, error) {
+	stream := &Stream{}
+	var serial uint32
+	var packets [][]byte
+	var packet []byte
+	var granule int64
+	for {
+		p, err := readPage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stream.Pages == 0 {
+			serial = p.serial
+		} else if p.serial != serial {
+			continue
+		}
+		stream.Pages++
+		stream.Bytes += int64(p.size)
+		if p.granule >= 0 {
+			granule = p.granule
+		}
+		// Only the identification and comment headers are needed, a
+		// segment shorter than 255 bytes ends a packet.
+		data := p.data
+		for _, s := range p.segments {
+			if len(packets) >= 2 {
+				break
+			}
+			packet = append(packet, data[:s]...)
+			data = data[s:]
+			if s < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+	if len(packets) == 0 {
+		return nil, errors.New("The Ogg file has no packets")
+	}
+	if err := stream.identify(packets[0]); err != nil {
+		return nil, err
+	}
+	if len(packets) > 1 {
+		stream.readComments(packets[1])
+	}
+	samples := granule - int64(stream.PreSkip)
+	if samples > 0 && stream.SampleRate > 0 {
+		stream.Duration = time.Duration(samples) * time.Second / time.Duration(stream.SampleRate)
+	}
+	return stream, nil
+}
+
+// ReadFile reads the stream information of the Ogg file at path.
+func ReadFile(path string) (*Stream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// identify reads the identification header of Opus or Vorbis.
+func (s *Stream) identify(packet []byte) error {
+	switch {
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 19:
+		s.Codec = "opus"
+		s.Channels = int(packet[9])
+		s.PreSkip = int(binary.LittleEndian.Uint16(packet[10:12]))
+		s.InputSampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+		s.SampleRate = 48000
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		s.Codec = "vorbis"
+		s.Channels = int(packet[11])
+		s.SampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+	default:
+		return errors.New("The Ogg stream is not Opus or Vorbis")
+	}
+	return nil
+}
+
+// readComments reads the vendor and comments of an OpusTags or Vorbis
+// comment header.
+func (s *Stream) readComments(packet []byte) {
+	switch {
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		packet = packet[8:]
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		packet = packet[7:]
+	default:
+		return
+	}
+	next := func() (string, bool) {
+		if len(packet) < 4 {
+			return "", false
+		}
+		n := int(binary.LittleEndian.Uint32(packet))
+		if n > len(packet)-4 {
+			return "", false
+		}
+		value := string(packet[4 : 4+n])
+		packet = packet[4+n:]
+		return value, true
+	}
+	var ok bool
+	if s.Vendor, ok = next(); !ok || len(packet) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(packet))
+	packet = packet[4:]
+	for i := 0; i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		s.Comments = append(s.Comments, comment)
+	}
+}
@@ -0,0 +1,69 @@
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePage writes an Ogg page with a single packet.
+func writePage(b *bytes.Buffer, granule int64, packet []byte) {
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	binary.LittleEndian.PutUint64(header[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(header[14:18], 1)
+	var segments []byte
+	for n := len(packet); ; n -= 255 {
+		if n < 255 {
+			segments = append(segments, byte(n))
+			break
+		}
+		segments = append(segments, 255)
+	}
+	header[26] = byte(len(segments))
+	b.Write(header)
+	b.Write(segments)
+	b.Write(packet)
+}
+
+func opusTags(vendor string, comments ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("OpusTags")
+	binary.Write(&b, binary.LittleEndian, uint32(len(vendor)))
+	b.WriteString(vendor)
+	binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&b, binary.LittleEndian, uint32(len(c)))
+		b.WriteString(c)
+	}
+	return b.Bytes()
+}
+
+func TestReadOpus(t *testing.T) {
+	head := []byte("OpusHead\x01\x01\x38\x01\xC0\x5D\x00\x00\x00\x00\x00")
+	var file bytes.Buffer
+	writePage(&file, 0, head)
+	writePage(&file, 0, opusTags("libopus 1.3", "TITLE=Episode", "ARTIST=Hacker News"))
+	writePage(&file, 48000+312, make([]byte, 300))
+	writePage(&file, 2*48000+312, make([]byte, 300))
+
+	stream, err := Read(&file)
+	assert.Nil(t, err)
+	assert.Equal(t, "opus", stream.Codec)
+	assert.Equal(t, 1, stream.Channels)
+	assert.Equal(t, 312, stream.PreSkip)
+	assert.Equal(t, 24000, stream.InputSampleRate)
+	assert.Equal(t, 48000, stream.SampleRate)
+	assert.Equal(t, 2*time.Second, stream.Duration)
+	assert.Equal(t, 4, stream.Pages)
+	assert.Equal(t, "libopus 1.3", stream.Vendor)
+	assert.Equal(t, []string{"TITLE=Episode", "ARTIST=Hacker News"}, stream.Comments)
+}
+
+func TestReadNotOgg(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("RIFF....WAVEfmt ")))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,190 @@
+package splice
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/dmulholland/mp3lib"
+)
+
+// Resolver finds the local file for the source of an SSML <audio> element.
+type Resolver struct {
+	// AssetDir holds the files that sources are looked up in by their file
+	// name.
+	AssetDir string
+	// CacheDir holds the files downloaded from http and https sources. No
+	// files are downloaded without it.
+	CacheDir string
+	// Client downloads the files, nil means a client that gives up after
+	// DownloadTimeout.
+	Client *http.Client
+}
+
+// DownloadTimeout limits the download of an audio source by the default
+// client of a Resolver.
+const DownloadTimeout = time.Minute
+
+// Resolve returns the path of the local file for src. A source is first
+// looked up by its file name in the asset directory, a URL is then looked up
+// in the cache and downloaded to it when it is not there yet.
+func (r *Resolver) Resolve(src string) (string, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	if len(r.AssetDir) > 0 {
+		name := path.Base(u.Path)
+		if p := filepath.Join(r.AssetDir, name); name != "/" && name != "." && fileExists(p) {
+			return p, nil
+		}
+	}
+	switch u.Scheme {
+	case "", "file":
+		if fileExists(u.Path) {
+			return u.Path, nil
+		}
+		return "", fmt.Errorf("Audio file %s not found", src)
+	case "http", "https":
+		return r.cached(src, path.Ext(u.Path))
+	}
+	return "", fmt.Errorf("Unsupported audio source %s", src)
+}
+
+// cached returns the cached file for a URL, downloading it first when it is
+// not in the cache.
+func (r *Resolver) cached(src, ext string) (string, error) {
+	if len(r.CacheDir) == 0 {
+		return "", fmt.Errorf("Audio %s is not in the asset directory and there is no cache directory to download it to", src)
+	}
+	p := filepath.Join(r.CacheDir, fmt.Sprintf("%x%s", sha1.Sum([]byte(src)), ext))
+	if fileExists(p) {
+		return p, nil
+	}
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: DownloadTimeout}
+	}
+	resp, err := client.Get(src)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to download %s: %s", src, resp.Status)
+	}
+	if err := os.MkdirAll(r.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	// Download to a temporary file so that an interrupted download does not
+	// end up in the cache.
+	tmp := p + ".download"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return p, os.Rename(tmp, p)
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// Clip writes the part of the audio in src from begin to end to dest. A zero
+// end clips until the end of the audio. A .wav dest takes any source that
+// Load can decode, an .mp3 dest only takes MP3 audio, which is clipped at the
+// frames that start in the range.
+func Clip(ctx context.Context, src, dest string, begin, end time.Duration) error {
+	switch strings.ToLower(filepath.Ext(dest)) {
+	case ".wav":
+		audio, err := Load(ctx, src, wav.Format{})
+		if err != nil {
+			return err
+		}
+		first, last := clipFrames(audio, begin, end)
+		channels := audio.Format.Channels
+		audio.Samples = audio.Samples[first*channels : last*channels]
+		return wav.WriteFile(dest, audio)
+	case ".mp3":
+		if strings.ToLower(filepath.Ext(src)) != ".mp3" {
+			return fmt.Errorf("Cannot clip %s into MP3 output, only MP3 audio can be copied into it", src)
+		}
+		return clipMP3(src, dest, begin, end)
+	}
+	return fmt.Errorf("Cannot clip to %s, only .wav and .mp3 files are supported", dest)
+}
+
+// clipFrames returns the range of sample frames between begin and end.
+func clipFrames(audio *wav.Audio, begin, end time.Duration) (int, int) {
+	frames := audio.Frames()
+	at := func(d time.Duration) int {
+		n := int(int64(d) * int64(audio.Format.SampleRate) / int64(time.Second))
+		if n > frames {
+			return frames
+		}
+		return n
+	}
+	first, last := at(begin), frames
+	if end > 0 {
+		last = at(end)
+	}
+	if last < first {
+		last = first
+	}
+	return first, last
+}
+
+func clipMP3(src, dest string, begin, end time.Duration) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	var at time.Duration
+	isFirstFrame := true
+	for {
+		frame := mp3lib.NextFrame(in)
+		if frame == nil {
+			break
+		}
+		if isFirstFrame {
+			isFirstFrame = false
+			if mp3lib.IsXingHeader(frame) || mp3lib.IsVbriHeader(frame) {
+				continue
+			}
+		}
+		if at >= begin && (end == 0 || at < end) {
+			if _, err := out.Write(frame.RawBytes); err != nil {
+				out.Close()
+				return err
+			}
+		}
+		if frame.SamplingRate > 0 {
+			at += time.Duration(frame.SampleCount) * time.Second / time.Duration(frame.SamplingRate)
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,91 @@
+package splice
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splice")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assets, cache := filepath.Join(dir, "assets"), filepath.Join(dir, "cache")
+	assert.Nil(t, os.Mkdir(assets, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(assets, "jingle.mp3"), []byte("jingle"), 0644))
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprint(w, "remote")
+	}))
+	defer server.Close()
+
+	r := &Resolver{AssetDir: assets}
+	p, err := r.Resolve("https://example.com/sounds/jingle.mp3")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(assets, "jingle.mp3"), p)
+	_, err = r.Resolve(server.URL + "/outro.mp3")
+	assert.NotNil(t, err)
+
+	r.CacheDir = cache
+	for i := 0; i < 2; i++ {
+		p, err = r.Resolve(server.URL + "/outro.mp3")
+		assert.Nil(t, err)
+		assert.Equal(t, ".mp3", filepath.Ext(p))
+		content, err := ioutil.ReadFile(p)
+		assert.Nil(t, err)
+		assert.Equal(t, "remote", string(content))
+	}
+	assert.Equal(t, 1, downloads)
+}
+
+func TestClipWav(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splice")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	src, dest := filepath.Join(dir, "src.wav"), filepath.Join(dir, "dest.wav")
+	samples := make([]int16, 20)
+	for i := range samples {
+		samples[i] = int16(i / 2)
+	}
+	assert.Nil(t, wav.WriteFile(src, &wav.Audio{Format: wav.Format{SampleRate: 10, Channels: 2}, Samples: samples}))
+
+	assert.Nil(t, Clip(context.Background(), src, dest, 200*time.Millisecond, 500*time.Millisecond))
+	clipped, err := wav.ReadFile(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{2, 2, 3, 3, 4, 4}, clipped.Samples)
+
+	assert.Nil(t, Clip(context.Background(), src, dest, 800*time.Millisecond, 0))
+	clipped, err = wav.ReadFile(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{8, 8, 9, 9}, clipped.Samples)
+
+	assert.NotNil(t, Clip(context.Background(), src, filepath.Join(dir, "dest.mp3"), 0, 0))
+}
+
+func TestClipDecodesOtherFormatsToWav(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splice")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	decoded := filepath.Join(dir, "decoded.wav")
+	assert.Nil(t, wav.WriteFile(decoded, &wav.Audio{Format: wav.Format{SampleRate: 10, Channels: 1}, Samples: []int16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}}))
+	// A fake ffmpeg that decodes every file to the same audio.
+	defer func(ffmpeg string) { FFmpeg = ffmpeg }(FFmpeg)
+	FFmpeg = filepath.Join(dir, "ffmpeg")
+	assert.Nil(t, ioutil.WriteFile(FFmpeg, []byte("#!/bin/sh\ncat \""+decoded+"\"\n"), 0755))
+
+	dest := filepath.Join(dir, "dest.wav")
+	assert.Nil(t, Clip(context.Background(), filepath.Join(dir, "jingle.mp3"), dest, 300*time.Millisecond, 600*time.Millisecond))
+	clipped, err := wav.ReadFile(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{3, 4, 5}, clipped.Samples)
+}
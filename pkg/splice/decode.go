@@ -0,0 +1,98 @@
+package splice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/dsp"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+)
+
+// FFmpeg is the command that decodes audio files that are not WAV.
+var FFmpeg = "ffmpeg"
+
+// Load decodes an audio file to PCM in the given format, zero fields of the
+// format keep the value of the file. WAV files are read directly, other
+// formats like MP3 are decoded with ffmpeg.
+func Load(ctx context.Context, path string, format wav.Format) (*wav.Audio, error) {
+	var audio *wav.Audio
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".wav" {
+		audio, err = wav.ReadFile(path)
+		if err != nil && !errors.Is(err, wav.ErrUnsupported) {
+			return nil, err
+		}
+	}
+	if audio == nil {
+		if audio, err = decode(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+	return dsp.Convert(audio, format), nil
+}
+
+// decode decodes a file to 16-bit PCM with ffmpeg.
+func decode(ctx context.Context, path string) (*wav.Audio, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, FFmpeg, "-hide_banner", "-loglevel", "error", "-i", path, "-vn", "-f", "wav", "-acodec", "pcm_s16le", "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s with %s. Error: %s %s", path, FFmpeg, err, strings.TrimSpace(stderr.String()))
+	}
+	audio, err := wav.Decode(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the decoded audio of %s. Error: %s", path, err)
+	}
+	return audio, nil
+}
+
+// Conform converts the WAV segments to the given format, zero fields of the
+// format take the value of the first synthesized segment. Synthesized
+// segments are converted in place, assets are decoded and converted to
+// copies in dir. It returns the paths of the copies.
+func Conform(ctx context.Context, segments []Segment, format wav.Format, dir string) ([]string, error) {
+	for _, s := range segments {
+		if s.Asset || format.SampleRate > 0 && format.Channels > 0 {
+			continue
+		}
+		speech, err := Probe(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		if format.SampleRate == 0 {
+			format.SampleRate = speech.SampleRate
+		}
+		if format.Channels == 0 {
+			format.Channels = speech.Channels
+		}
+		break
+	}
+	var copies []string
+	for i, s := range segments {
+		if !s.Asset && strings.ToLower(filepath.Ext(s.Path)) != ".wav" {
+			continue
+		}
+		current, err := Probe(s.Path)
+		if err == nil && current == (Format{Kind: "wav", SampleRate: format.SampleRate, Channels: format.Channels}) {
+			continue
+		}
+		audio, err := Load(ctx, s.Path, format)
+		if err != nil {
+			return copies, err
+		}
+		if s.Asset {
+			segments[i].Path = filepath.Join(dir, fmt.Sprintf("conformed%d.wav", i))
+			copies = append(copies, segments[i].Path)
+		}
+		if err := wav.WriteFile(segments[i].Path, audio); err != nil {
+			return copies, err
+		}
+	}
+	return copies, nil
+}
@@ -0,0 +1,47 @@
+package splice
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splice")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	speech, music := filepath.Join(dir, "0.wav"), filepath.Join(dir, "music.wav")
+	assert.Nil(t, wav.WriteFile(speech, &wav.Audio{Format: wav.Format{SampleRate: 24000, Channels: 1}, Samples: make([]int16, 2400)}))
+	assert.Nil(t, wav.WriteFile(music, &wav.Audio{Format: wav.Format{SampleRate: 48000, Channels: 2}, Samples: make([]int16, 9600)}))
+
+	segments := Plan([]string{speech}, nil, Assets{Intro: []string{music}})
+	copies, err := Conform(context.Background(), segments, wav.Format{}, dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "conformed0.wav")}, copies)
+	assert.Equal(t, copies[0], segments[0].Path)
+	assert.Nil(t, Check(segments))
+	conformed, err := wav.ReadFile(copies[0])
+	assert.Nil(t, err)
+	assert.Equal(t, wav.Format{SampleRate: 24000, Channels: 1}, conformed.Format)
+	assert.Equal(t, 2400, conformed.Frames())
+
+	// A target format converts the speech as well.
+	copies, err = Conform(context.Background(), segments, wav.Format{SampleRate: 48000, Channels: 2}, dir)
+	assert.Nil(t, err)
+	converted, err := wav.ReadFile(speech)
+	assert.Nil(t, err)
+	assert.Equal(t, wav.Format{SampleRate: 48000, Channels: 2}, converted.Format)
+}
+
+func TestLoadWithoutFFmpeg(t *testing.T) {
+	defer func(ffmpeg string) { FFmpeg = ffmpeg }(FFmpeg)
+	FFmpeg = "/nonexistent/ffmpeg"
+	_, err := Load(context.Background(), "jingle.mp3", wav.Format{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "jingle.mp3")
+}
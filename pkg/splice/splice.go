@@ -0,0 +1,142 @@
+// Package splice orders local audio assets, like a jingle, a sponsor read or
+// outro music, around the synthesized chunks before they are merged.
+package splice
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/dmulholland/mp3lib"
+)
+
+// Assets are the audio files to splice in.
+type Assets struct {
+	// Intro is played before the first chunk.
+	Intro []string
+	// Between is played before every story but the first.
+	Between []string
+	// Outro is played after the last chunk.
+	Outro []string
+}
+
+// Segment is an input of the merge, either a synthesized chunk or an asset.
+type Segment struct {
+	Path string
+	// Chunk is the index of the chunk, or -1 for an asset.
+	Chunk int
+	// Asset is set for files that were not synthesized, including chunks
+	// that play an <audio> element.
+	Asset bool
+}
+
+// Plan returns the segments to merge, in order. chunkFiles holds the
+// synthesized file of every chunk and storyStarts the indexes of the chunks
+// that start a story.
+func Plan(chunkFiles []string, storyStarts []int, assets Assets) []Segment {
+	starts := make(map[int]bool)
+	for _, s := range storyStarts {
+		if s > 0 {
+			starts[s] = true
+		}
+	}
+	var segments []Segment
+	addAssets := func(paths []string) {
+		for _, p := range paths {
+			segments = append(segments, Segment{Path: p, Chunk: -1, Asset: true})
+		}
+	}
+	addAssets(assets.Intro)
+	for i, f := range chunkFiles {
+		if starts[i] {
+			addAssets(assets.Between)
+		}
+		segments = append(segments, Segment{Path: f, Chunk: i})
+	}
+	addAssets(assets.Outro)
+	return segments
+}
+
+// Paths returns the paths of the segments.
+func Paths(segments []Segment) []string {
+	paths := make([]string, len(segments))
+	for i, s := range segments {
+		paths[i] = s.Path
+	}
+	return paths
+}
+
+// Format describes the audio in a file, as far as it must match for files
+// to be merged.
+type Format struct {
+	Kind       string
+	SampleRate int
+	Channels   int
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("%s, %d Hz, %d channels", f.Kind, f.SampleRate, f.Channels)
+}
+
+// Probe returns the format of a WAV or MP3 file.
+func Probe(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		audio, err := wav.ReadFile(path)
+		if err != nil {
+			return Format{}, err
+		}
+		return Format{Kind: "wav", SampleRate: audio.Format.SampleRate, Channels: audio.Format.Channels}, nil
+	case ".mp3":
+		f, err := os.Open(path)
+		if err != nil {
+			return Format{}, err
+		}
+		defer f.Close()
+		frame := mp3lib.NextFrame(f)
+		if frame == nil {
+			return Format{}, fmt.Errorf("%s has no MP3 frames", path)
+		}
+		channels := 2
+		if frame.ChannelMode == mp3lib.Mono {
+			channels = 1
+		}
+		return Format{Kind: "mp3", SampleRate: frame.SamplingRate, Channels: channels}, nil
+	}
+	return Format{}, fmt.Errorf("Cannot splice %s, only .wav and .mp3 files are supported", path)
+}
+
+// Check returns an error when an asset does not have the format of the
+// synthesized audio, which cannot be merged with it.
+func Check(segments []Segment) error {
+	var chunkFormat *Format
+	for _, s := range segments {
+		if s.Asset {
+			continue
+		}
+		format, err := Probe(s.Path)
+		if err != nil {
+			return err
+		}
+		chunkFormat = &format
+		break
+	}
+	if chunkFormat == nil {
+		return nil
+	}
+	for _, s := range segments {
+		if !s.Asset {
+			continue
+		}
+		format, err := Probe(s.Path)
+		if err != nil {
+			return err
+		}
+		if format != *chunkFormat {
+			return fmt.Errorf("Asset %s has format %s but the speech has format %s, convert it first", s.Path, format, chunkFormat)
+		}
+	}
+	return nil
+}
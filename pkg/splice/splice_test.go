@@ -0,0 +1,45 @@
+package splice
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	segments := Plan([]string{"0.mp3", "1.mp3", "2.mp3"}, []int{0, 2}, Assets{
+		Intro:   []string{"jingle.mp3", "sponsor.mp3"},
+		Between: []string{"sting.mp3"},
+		Outro:   []string{"outro.mp3"},
+	})
+	assert.Equal(t, []Segment{
+		{Path: "jingle.mp3", Chunk: -1, Asset: true},
+		{Path: "sponsor.mp3", Chunk: -1, Asset: true},
+		{Path: "0.mp3", Chunk: 0},
+		{Path: "1.mp3", Chunk: 1},
+		{Path: "sting.mp3", Chunk: -1, Asset: true},
+		{Path: "2.mp3", Chunk: 2},
+		{Path: "outro.mp3", Chunk: -1, Asset: true},
+	}, segments)
+	assert.Equal(t, "sting.mp3", Paths(segments)[4])
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splice")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	speech, jingle, music := filepath.Join(dir, "0.wav"), filepath.Join(dir, "jingle.wav"), filepath.Join(dir, "music.wav")
+	assert.Nil(t, wav.WriteFile(speech, &wav.Audio{Format: wav.Format{SampleRate: 24000, Channels: 1}}))
+	assert.Nil(t, wav.WriteFile(jingle, &wav.Audio{Format: wav.Format{SampleRate: 24000, Channels: 1}}))
+	assert.Nil(t, wav.WriteFile(music, &wav.Audio{Format: wav.Format{SampleRate: 44100, Channels: 2}}))
+
+	assert.Nil(t, Check(Plan([]string{speech}, nil, Assets{Intro: []string{jingle}})))
+	err = Check(Plan([]string{speech}, nil, Assets{Outro: []string{music}}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "44100 Hz")
+	assert.NotNil(t, Check(Plan([]string{speech}, nil, Assets{Outro: []string{filepath.Join(dir, "music.ogg")}})))
+}
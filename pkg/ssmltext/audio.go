@@ -0,0 +1,58 @@
+package ssmltext
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Audio is an SSML <audio> element that is played from a local file.
+type Audio struct {
+	Src string
+	// ClipBegin and ClipEnd select the part of the audio to play, a zero
+	// ClipEnd plays until the end.
+	ClipBegin time.Duration
+	ClipEnd   time.Duration
+	// Fallback is the plain text of the content of the element, which is
+	// read when the audio cannot be played.
+	Fallback string
+}
+
+var timeDesignation = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)(ms|s)\s*$`)
+
+// parseTime parses an SSML time designation like "500ms" or "9s".
+func parseTime(s string) (time.Duration, error) {
+	m := timeDesignation.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("Invalid time '%s', expected a number of ms or s", s)
+	}
+	value, _ := strconv.ParseFloat(m[1], 64)
+	if m[2] == "s" {
+		value *= 1000
+	}
+	return time.Duration(value * float64(time.Millisecond)), nil
+}
+
+// addAudio adds an <audio> element as a chunk of its own.
+func (r *renderer) addAudio(s *goquery.Selection, voice string) {
+	a := &Audio{Src: s.AttrOr("src", ""), Fallback: normalizeSpace(s.Text())}
+	// The html parser lower cases the attribute names.
+	for attr, d := range map[string]*time.Duration{"clipbegin": &a.ClipBegin, "clipend": &a.ClipEnd} {
+		if value, ok := s.Attr(attr); ok {
+			var err error
+			if *d, err = parseTime(value); err != nil {
+				r.chunks.lastErr = err
+				return
+			}
+		}
+	}
+	fallback, _ := s.Html()
+	if len(a.Fallback) == 0 {
+		// The API does not synthesize an empty <speak> element.
+		fallback = br(0)
+	}
+	r.chunks.addAudio(a, fallback, voice)
+}
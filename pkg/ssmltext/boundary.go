@@ -0,0 +1,65 @@
+package ssmltext
+
+import "time"
+
+// Boundary is the kind of break in the document before a paragraph.
+type Boundary int
+
+const (
+	// BoundaryParagraph is the break between paragraphs.
+	BoundaryParagraph Boundary = iota
+	// BoundarySection is the break before a heading or a <section>.
+	BoundarySection
+	// BoundaryStory is the break before an <article>.
+	BoundaryStory
+)
+
+// ParagraphPause is the default break after every paragraph.
+const ParagraphPause = 800 * time.Millisecond
+
+// paragraphPause returns the break after every paragraph.
+func (o Options) paragraphPause() time.Duration {
+	if o.ParagraphPause <= 0 {
+		return ParagraphPause
+	}
+	return o.ParagraphPause
+}
+
+// markBoundary records a boundary before the next paragraph, the strongest
+// boundary wins.
+func (r *renderer) markBoundary(b Boundary) {
+	if b > r.chunks.boundary {
+		r.chunks.boundary = b
+	}
+}
+
+// boundaryPause returns the pause that the options set for a boundary, which
+// is at least the pause after a paragraph.
+func (o Options) boundaryPause(b Boundary) time.Duration {
+	pause := o.paragraphPause()
+	switch b {
+	case BoundarySection:
+		pause = o.SectionPause
+	case BoundaryStory:
+		pause = o.StoryPause
+	}
+	if pause < o.paragraphPause() {
+		return o.paragraphPause()
+	}
+	return pause
+}
+
+// addBoundaryPause lengthens the pause before a paragraph at a section or
+// story boundary inside a chunk, so that it sounds like the gap that is
+// inserted when the boundary falls between chunks.
+func (r *renderer) addBoundaryPause(voice string) {
+	c := &r.chunks
+	extra := r.opts.boundaryPause(c.boundary) - r.opts.paragraphPause()
+	if extra <= 0 || len(c.chunkHtml) == 0 || voice != c.current.Voice {
+		return
+	}
+	fragment := br(int(extra / time.Millisecond))
+	if len(c.chunkHtml)+len(fragment) <= c.maxChunkChars {
+		c.add(fragment, voice, nil)
+	}
+}
@@ -0,0 +1,114 @@
+package ssmltext
+
+import (
+	"errors"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ChapterPolicy sets where html documents are divided into chapters. A
+// chapter always starts a new chunk, so that its offset in the audio is the
+// measured start of that chunk.
+type ChapterPolicy int
+
+const (
+	// ChaptersNone does not divide the document.
+	ChaptersNone ChapterPolicy = iota
+	// ChaptersStories starts a chapter at every <article>.
+	ChaptersStories
+	// ChaptersSections starts a chapter at every heading, <section> and
+	// <article>.
+	ChaptersSections
+)
+
+// ParseChapterPolicy parses none, stories or sections.
+func ParseChapterPolicy(s string) (ChapterPolicy, error) {
+	switch s {
+	case "none", "":
+		return ChaptersNone, nil
+	case "stories":
+		return ChaptersStories, nil
+	case "sections":
+		return ChaptersSections, nil
+	}
+	return ChaptersNone, errors.New("Unknown chapter policy " + s + ", expected none, stories or sections")
+}
+
+// Chapter is a part of the document that listeners can skip to.
+type Chapter struct {
+	// Title is the text of the heading or story title, empty for the
+	// content before the first chapter.
+	Title string
+	// URL is the link of the heading or the data-url of the story.
+	URL string
+	// Chunk is the index of the chunk that the chapter starts with.
+	Chunk int
+	// Paragraph is the index of the first paragraph of the chapter.
+	Paragraph int
+}
+
+// startChapter starts a chapter at the next paragraph. When nothing was read
+// since the previous chapter started, that chapter is replaced, keeping its
+// title when the new chapter has none.
+func (r *renderer) startChapter(title, url string) {
+	if len(r.chunks.chunkHtml) > 0 {
+		r.chunks.flush()
+	}
+	if n := len(r.chapters); n > 0 && r.chapters[n-1].Paragraph == r.paragraphs {
+		if len(title) == 0 {
+			title, url = r.chapters[n-1].Title, r.chapters[n-1].URL
+		}
+		r.chapters = r.chapters[:n-1]
+	}
+	r.chapters = append(r.chapters, Chapter{Title: title, URL: url, Chunk: len(r.chunks.chunks), Paragraph: r.paragraphs})
+}
+
+// chapterBoundary starts a chapter when block s is a heading, or is the first
+// block of a section or story, as the chapter policy requires.
+func (r *renderer) chapterBoundary(s *goquery.Selection, section, story *html.Node) {
+	switch r.opts.Chapters {
+	case ChaptersSections:
+		if s.Is(headingSelector) {
+			r.startChapter(headingTitle(s))
+		} else if container := firstNode(s.Closest("section, article")); container != section && container != nil {
+			r.startChapter(containerTitle(s.Closest("section, article")))
+		}
+	case ChaptersStories:
+		if container := firstNode(s.Closest("article")); container != story && container != nil {
+			r.startChapter(containerTitle(s.Closest("article")))
+		}
+	}
+}
+
+// chaptersOf returns the chapters that have content, with a chapter without
+// title for the content before the first chapter.
+func (r *renderer) chaptersOf(chunks []Chunk) []Chapter {
+	var chapters []Chapter
+	for _, c := range r.chapters {
+		if c.Paragraph < r.paragraphs && c.Chunk < len(chunks) {
+			chapters = append(chapters, c)
+		}
+	}
+	if len(chapters) > 0 && chapters[0].Paragraph > 0 {
+		chapters = append([]Chapter{{}}, chapters...)
+	}
+	return chapters
+}
+
+func headingTitle(s *goquery.Selection) (string, string) {
+	return normalizeSpace(s.Text()), s.Find("a[href]").First().AttrOr("href", "")
+}
+
+// containerTitle returns the data-title of a section or story, or the title
+// of its first heading, and its data-url or the link of that heading.
+func containerTitle(s *goquery.Selection) (string, string) {
+	title, url := headingTitle(s.Find(headingSelector).First())
+	if t := s.AttrOr("data-title", ""); len(t) > 0 {
+		title = normalizeSpace(t)
+	}
+	if u := s.AttrOr("data-url", ""); len(u) > 0 {
+		url = u
+	}
+	return title, url
+}
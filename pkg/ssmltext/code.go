@@ -0,0 +1,258 @@
+package ssmltext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// CodePolicy controls how code blocks in <pre> elements are read.
+type CodePolicy int
+
+const (
+	// CodeSkip leaves out code blocks with a spoken cue.
+	CodeSkip CodePolicy = iota
+	// CodeRead reads short code blocks symbol by symbol and summarizes the
+	// longer ones.
+	CodeRead
+	// CodeSummarize describes the language, length and definitions of a
+	// code block.
+	CodeSummarize
+)
+
+// Code blocks up to maxReadCodeLines lines and maxReadCodeChars characters
+// are read out with CodeRead. Inline code longer than maxInlineCodeChars is
+// handled like a code block.
+const (
+	maxReadCodeLines   = 3
+	maxReadCodeChars   = 200
+	maxInlineCodeChars = 60
+)
+
+func ParseCodePolicy(s string) (CodePolicy, error) {
+	switch s {
+	case "skip":
+		return CodeSkip, nil
+	case "read":
+		return CodeRead, nil
+	case "summarize":
+		return CodeSummarize, nil
+	}
+	return CodeSkip, fmt.Errorf("Unknown code policy '%s', expected skip, read or summarize", s)
+}
+
+var (
+	codeLanguageClass = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+	codeDefinition    = regexp.MustCompile(`(?m)^\s*(?:export\s+)?(?:func(?:\s*\([^)]*\))?|def|function|class|fn|struct|type|interface)\s+([A-Za-z_]\w*)`)
+)
+
+var codeLanguageNames = map[string]string{
+	"bash":       "shell",
+	"c":          "C",
+	"c++":        "C++",
+	"console":    "shell",
+	"cpp":        "C++",
+	"cs":         "C#",
+	"csharp":     "C#",
+	"css":        "CSS",
+	"go":         "Go",
+	"golang":     "Go",
+	"html":       "HTML",
+	"java":       "Java",
+	"javascript": "JavaScript",
+	"js":         "JavaScript",
+	"json":       "JSON",
+	"py":         "Python",
+	"python":     "Python",
+	"rb":         "Ruby",
+	"ruby":       "Ruby",
+	"rs":         "Rust",
+	"rust":       "Rust",
+	"sh":         "shell",
+	"shell":      "shell",
+	"sql":        "SQL",
+	"ts":         "TypeScript",
+	"typescript": "TypeScript",
+	"yaml":       "YAML",
+	"yml":        "YAML",
+}
+
+// codeSymbols holds the spoken names of the symbols in code. Symbols of two
+// characters are matched before single characters.
+var codeSymbols = map[string]string{
+	"==": "double equals", "!=": "not equals", ":=": "colon equals", "<=": "less or equal",
+	">=": "greater or equal", "=>": "arrow", "->": "arrow", "&&": "and and", "||": "or or",
+	"++": "plus plus", "--": "minus minus", "::": "double colon",
+	"{": "open brace", "}": "close brace", "(": "open paren", ")": "close paren",
+	"[": "open bracket", "]": "close bracket", "<": "less than", ">": "greater than",
+	";": "semicolon", ",": "comma", ".": "dot", ":": "colon", "=": "equals",
+	"+": "plus", "-": "minus", "*": "star", "/": "slash", `\`: "backslash",
+	"&": "ampersand", "|": "pipe", "!": "bang", "?": "question mark", `"`: "quote",
+	"'": "single quote", "`": "backtick", "#": "hash", "$": "dollar", "%": "percent",
+	"^": "caret", "~": "tilde", "@": "at",
+}
+
+// renderCode renders a <pre> block according to the code policy.
+func (r *renderer) renderCode(s *goquery.Selection) []string {
+	return r.code(s.Nodes[0])
+}
+
+func (r *renderer) code(n *html.Node) []string {
+	code := strings.Trim(nodeText(n), "\n")
+	if len(strings.TrimSpace(code)) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(code, "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	language := codeLanguage(n)
+	switch r.opts.Code {
+	case CodeRead:
+		if len(lines) <= maxReadCodeLines && len(code) <= maxReadCodeChars {
+			var paragraphs []string
+			for _, line := range lines {
+				paragraphs = append(paragraphs, speakCode(line))
+			}
+			return paragraphs
+		}
+		return []string{summarizeCode(language, lines, code)}
+	case CodeSummarize:
+		return []string{summarizeCode(language, lines, code)}
+	}
+	cue := "Code sample omitted, " + plural(len(lines), "line") + "."
+	if len(language) > 0 {
+		cue = language + " code sample omitted, " + plural(len(lines), "line") + "."
+	}
+	return []string{cue}
+}
+
+// writeInlineCode writes the contents of a <code> or <kbd> element. Short
+// fragments are read symbol by symbol, longer ones like a code block.
+func (r *renderer) writeInlineCode(b *strings.Builder, n *html.Node) {
+	code := normalizeSpace(nodeText(n))
+	if len(code) <= maxInlineCodeChars {
+		b.WriteString(speakCode(code))
+		return
+	}
+	b.WriteString(strings.TrimSuffix(strings.Join(r.code(n), " "), "."))
+}
+
+func summarizeCode(language string, lines []string, code string) string {
+	summary := "A code sample of " + plural(len(lines), "line")
+	if len(language) > 0 {
+		summary = "A " + language + " code sample of " + plural(len(lines), "line")
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range codeDefinition.FindAllStringSubmatch(code, -1) {
+		if !seen[m[1]] && len(names) < 3 {
+			seen[m[1]] = true
+			names = append(names, splitIdentifier(m[1]))
+		}
+	}
+	switch len(names) {
+	case 0:
+	case 1:
+		summary += " that defines " + names[0]
+	default:
+		summary += " that defines " + strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	}
+	return addBreaks(summary + ".")
+}
+
+// codeLanguage returns the name of the programming language of a code block
+// as declared in the class or data-lang attribute of the block or the
+// <code> element in it.
+func codeLanguage(n *html.Node) string {
+	for _, e := range []*html.Node{n, n.FirstChild} {
+		if e == nil || e.Type != html.ElementNode {
+			continue
+		}
+		language := attr(e, "data-lang")
+		if m := codeLanguageClass.FindStringSubmatch(attr(e, "class")); len(language) == 0 && m != nil {
+			language = m[1]
+		}
+		if len(language) > 0 {
+			if name, ok := codeLanguageNames[strings.ToLower(language)]; ok {
+				return name
+			}
+			return language
+		}
+	}
+	return ""
+}
+
+// speakCode spells out the symbols in code and splits identifiers into
+// words, so that it can be read without pauses at every comma and without
+// reading braces as garbage.
+func speakCode(code string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, splitIdentifier(string(word)))
+			word = nil
+		}
+	}
+	runes := []rune(code)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			word = append(word, c)
+			continue
+		case unicode.IsSpace(c):
+			flush()
+			continue
+		}
+		flush()
+		if i+1 < len(runes) {
+			if name, ok := codeSymbols[string(runes[i:i+2])]; ok {
+				words = append(words, name)
+				i++
+				continue
+			}
+		}
+		if name, ok := codeSymbols[string(c)]; ok {
+			words = append(words, name)
+		} else {
+			words = append(words, string(c))
+		}
+	}
+	flush()
+	return strings.Join(words, " ")
+}
+
+// splitIdentifier splits camelCase and snake_case identifiers into words.
+func splitIdentifier(identifier string) string {
+	var words []string
+	var word []rune
+	runes := []rune(identifier)
+	for i, c := range runes {
+		if c == '_' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		}
+		boundary := i > 0 && unicode.IsUpper(c) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])))
+		if boundary && len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, c)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,241 @@
+package ssmltext
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// LinkPolicy controls what happens with the url of a link. The anchor text
+// is always read.
+type LinkPolicy int
+
+const (
+	// LinksSilent discards the url.
+	LinksSilent LinkPolicy = iota
+	// LinksAnnounce says "link" after the anchor text.
+	LinksAnnounce
+	// LinksCollect adds the link to Document.Links.
+	LinksCollect
+)
+
+// FootnotePolicy controls whether the bodies of footnotes are read. Footnote
+// markers such as [1] or a superscript reference are never read.
+type FootnotePolicy int
+
+const (
+	// FootnotesDrop leaves out the footnote bodies.
+	FootnotesDrop FootnotePolicy = iota
+	// FootnotesEndOfSection reads the footnotes referenced in a section at
+	// the end of that section.
+	FootnotesEndOfSection
+	// FootnotesEndOfArticle reads all referenced footnotes at the end.
+	FootnotesEndOfArticle
+)
+
+// Link is a link that is read in the document.
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+	// Chunk is the index of the chunk in which the link is read.
+	Chunk int `json:"chunk"`
+	// Paragraph is the index of the paragraph in which the link is read,
+	// counted over the whole document.
+	Paragraph int `json:"paragraph"`
+	read      bool
+}
+
+func ParseLinkPolicy(s string) (LinkPolicy, error) {
+	switch s {
+	case "silent":
+		return LinksSilent, nil
+	case "announce":
+		return LinksAnnounce, nil
+	case "collect":
+		return LinksCollect, nil
+	}
+	return LinksSilent, fmt.Errorf("Unknown link policy '%s', expected silent, announce or collect", s)
+}
+
+func ParseFootnotePolicy(s string) (FootnotePolicy, error) {
+	switch s {
+	case "drop":
+		return FootnotesDrop, nil
+	case "section":
+		return FootnotesEndOfSection, nil
+	case "article":
+		return FootnotesEndOfArticle, nil
+	}
+	return FootnotesDrop, fmt.Errorf("Unknown footnote policy '%s', expected drop, section or article", s)
+}
+
+var (
+	// footnoteMarkerText matches the text of a superscript footnote marker.
+	footnoteMarkerText = regexp.MustCompile(`^(\[\d{1,3}\]|[*†‡]+)$`)
+	// footnoteHref matches the anchors that footnote markers link to.
+	footnoteHref = regexp.MustCompile(`^#(fn|footnote|note|endnote|cite_note)`)
+	// linkMarkerPattern matches the markers that are put in the text at the
+	// place of a collected link.
+	linkMarkerPattern = regexp.MustCompile("\uE000(\\d+)\uE001")
+)
+
+// footnoteContainerSelector matches the elements that wrap the footnote
+// bodies at the end of an article.
+const footnoteContainerSelector = ".footnotes, .footnote, [role='doc-endnotes'], #footnotes"
+
+// footnoteBackrefSelector matches the links from a footnote body back to its
+// marker.
+const footnoteBackrefSelector = "a[href^='#fnref'], a.footnote-backref, a.reversefootnote, a[rev='footnote']"
+
+type footnote struct {
+	number     string
+	body       *goquery.Selection
+	referenced bool
+}
+
+type footnotes struct {
+	byID    map[string]*footnote
+	pending []*footnote
+}
+
+// extractFootnotes finds the footnote bodies that the footnote markers below
+// root link to and removes them from the document, so that they are only
+// read according to the footnote policy.
+func extractFootnotes(root *goquery.Selection) footnotes {
+	f := footnotes{byID: map[string]*footnote{}}
+	var bodies []*goquery.Selection
+	root.Find("a[href^='#']").Each(func(_ int, a *goquery.Selection) {
+		n := a.Nodes[0]
+		if a.Is(footnoteBackrefSelector) {
+			return
+		}
+		if !isFootnoteMarker(n) && !(n.Parent != nil && isFootnoteMarker(n.Parent)) {
+			return
+		}
+		id := strings.TrimPrefix(attr(n, "href"), "#")
+		if _, ok := f.byID[id]; ok {
+			return
+		}
+		body := root.Find("[id]").FilterFunction(func(_ int, e *goquery.Selection) bool {
+			return attr(e.Nodes[0], "id") == id
+		}).First()
+		if body.Length() == 0 {
+			return
+		}
+		number := strings.Trim(a.Text(), "[] ")
+		if _, err := strconv.Atoi(number); err != nil {
+			number = strconv.Itoa(len(f.byID) + 1)
+		}
+		f.byID[id] = &footnote{number: number, body: body}
+		bodies = append(bodies, body)
+	})
+	for _, body := range bodies {
+		container := body.Closest(footnoteContainerSelector)
+		body.Remove()
+		body.Find(footnoteBackrefSelector).Remove()
+		container.Remove()
+	}
+	return f
+}
+
+// isFootnoteMarker reports whether n is a reference to a footnote.
+func isFootnoteMarker(n *html.Node) bool {
+	switch n.Data {
+	case "sup":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "a" && strings.HasPrefix(attr(c, "href"), "#") {
+				return true
+			}
+		}
+		return footnoteMarkerText.MatchString(strings.TrimSpace(nodeText(n)))
+	case "a":
+		href := attr(n, "href")
+		if strings.HasPrefix(href, "#fnref") {
+			return false
+		}
+		return footnoteHref.MatchString(href) || strings.Contains(attr(n, "class"), "footnote-ref")
+	}
+	return false
+}
+
+// referenceFootnote queues the footnote that marker n refers to, so that it
+// is read at the end of the section or article.
+func (r *renderer) referenceFootnote(n *html.Node) {
+	href := attr(n, "href")
+	for c := n.FirstChild; len(href) == 0 && c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "a" {
+			href = attr(c, "href")
+		}
+	}
+	fn := r.footnotes.byID[strings.TrimPrefix(href, "#")]
+	if fn == nil || fn.referenced {
+		return
+	}
+	fn.referenced = true
+	r.footnotes.pending = append(r.footnotes.pending, fn)
+}
+
+// readFootnotes adds the bodies of the footnotes referenced so far.
+func (r *renderer) readFootnotes() {
+	for len(r.footnotes.pending) > 0 {
+		fn := r.footnotes.pending[0]
+		r.footnotes.pending = r.footnotes.pending[1:]
+		text := normalizeSpace(strings.Replace(r.text(fn.body), "↩", "", -1))
+		if len(text) == 0 {
+			continue
+		}
+		r.addParagraph(fmt.Sprintf("Footnote %s:%s %s", fn.number, br(300), addBreaks(text)), "")
+	}
+}
+
+// writeLink writes the anchor text of link n, applying the link policy.
+func (r *renderer) writeLink(b *strings.Builder, n *html.Node) {
+	var anchor strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.writeText(&anchor, c)
+	}
+	text := anchor.String()
+	b.WriteString(text)
+
+	href := attr(n, "href")
+	if len(strings.TrimSpace(text)) == 0 || len(href) == 0 || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+		return
+	}
+	switch r.opts.Links {
+	case LinksAnnounce:
+		b.WriteString(" (link)")
+	case LinksCollect:
+		b.WriteString(linkMarker(len(r.links)))
+		r.links = append(r.links, Link{Text: normalizeSpace(html.UnescapeString(text)), URL: href})
+	}
+}
+
+func linkMarker(i int) string {
+	return fmt.Sprintf("\uE000%d\uE001", i)
+}
+
+// extractLinkMarkers removes the link markers from text and returns the
+// indexes of the links they refer to.
+func extractLinkMarkers(text string) (string, []int) {
+	var links []int
+	for _, m := range linkMarkerPattern.FindAllStringSubmatch(text, -1) {
+		i, _ := strconv.Atoi(m[1])
+		links = append(links, i)
+	}
+	return linkMarkerPattern.ReplaceAllString(text, ""), links
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var text string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text += nodeText(c)
+	}
+	return text
+}
@@ -0,0 +1,249 @@
+package ssmltext
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// MarkPolicy sets where <mark> elements are inserted, the text-to-speech
+// API reports the time at which it reaches every mark.
+type MarkPolicy int
+
+const (
+	// MarksNone inserts no marks.
+	MarksNone MarkPolicy = iota
+	// MarksSentences inserts a mark at the start of every sentence.
+	MarksSentences
+	// MarksWords inserts a mark at the start of every word.
+	MarksWords
+)
+
+// ParseMarkPolicy parses none, sentences or words.
+func ParseMarkPolicy(s string) (MarkPolicy, error) {
+	switch s {
+	case "none", "":
+		return MarksNone, nil
+	case "sentences":
+		return MarksSentences, nil
+	case "words":
+		return MarksWords, nil
+	}
+	return MarksNone, errors.New("Unknown mark policy " + s + ", expected none, sentences or words")
+}
+
+// Mark is a <mark> element in a paragraph with the text that is read from
+// it up to the next mark.
+type Mark struct {
+	Name string
+	Text string
+}
+
+// insertMarks inserts marks in the SSML text of the paragraph with the
+// given index. Marks are named p<paragraph>.s<n> or p<paragraph>.w<n>, they
+// are only inserted in the text of the paragraph itself, the words in
+// nested elements like <say-as> belong to the mark before them.
+func insertMarks(text string, paragraph int, policy MarkPolicy) (string, []Mark) {
+	if policy == MarksNone {
+		return text, nil
+	}
+	prefix := "w"
+	if policy == MarksSentences {
+		prefix = "s"
+	}
+	var b strings.Builder
+	var marks []Mark
+	var words []string
+	endMark := func() {
+		if n := len(marks); n > 0 {
+			marks[n-1].Text = normalizeSpace(html.UnescapeString(strings.Join(words, " ")))
+		}
+		words = nil
+	}
+	depth := 0
+	sentenceEnded := true
+	for len(text) > 0 {
+		if text[0] == '<' {
+			end := strings.IndexByte(text, '>')
+			if end < 0 {
+				end = len(text) - 1
+			}
+			t := text[:end+1]
+			switch {
+			case strings.HasPrefix(t, "</"):
+				if !strings.HasPrefix(t, "</break") {
+					depth--
+				}
+			case strings.HasPrefix(t, "<break"), strings.HasSuffix(t, "/>"):
+			default:
+				depth++
+			}
+			b.WriteString(t)
+			text = text[end+1:]
+			continue
+		}
+		end := strings.IndexAny(text, "< \t\n\r")
+		if end < 0 {
+			end = len(text)
+		}
+		if end == 0 {
+			b.WriteByte(text[0])
+			text = text[1:]
+			continue
+		}
+		word := text[:end]
+		text = text[end:]
+		plain := html.UnescapeString(word)
+		startsMark := policy == MarksWords || len(marks) == 0
+		if !startsMark && sentenceEnded {
+			startsMark = startsSentence(plain)
+		}
+		if depth == 0 && startsMark && hasLetterOrDigit(plain) {
+			endMark()
+			name := fmt.Sprintf("p%d.%s%d", paragraph, prefix, len(marks))
+			marks = append(marks, Mark{Name: name})
+			fmt.Fprintf(&b, `<mark name="%s"></mark>`, name)
+			sentenceEnded = false
+		}
+		words = append(words, word)
+		if depth == 0 && endsSentence(plain) {
+			sentenceEnded = true
+		}
+		b.WriteString(word)
+	}
+	endMark()
+	return b.String(), marks
+}
+
+// splitForMarks splits the SSML text of the next paragraph into parts that
+// fit in a chunk once their marks are inserted, as marks take far more room
+// than the words they precede. It splits after a sentence where it can and
+// between words otherwise, never inside a nested element. The parts are
+// numbered as consecutive paragraphs.
+func (r *renderer) splitForMarks(text string) []string {
+	fits := func(part string, index int) bool {
+		part, _ = extractLinkMarkers(part)
+		marked, _ := insertMarks(part, index, r.opts.Marks)
+		return len(paragraph(marked, r.opts.paragraphPause())) <= r.chunks.maxChunkChars
+	}
+	sentences, words := splitPoints(text)
+	var parts []string
+	for len(strings.TrimSpace(text)) > 0 {
+		index := r.paragraphs + len(parts)
+		if fits(text, index) {
+			parts = append(parts, strings.TrimSpace(text))
+			break
+		}
+		end := 0
+		for _, points := range [][]int{sentences, words} {
+			for _, p := range points {
+				if p > 0 && p < len(text) && fits(text[:p], index) {
+					end = p
+				} else if p > 0 {
+					break
+				}
+			}
+			if end > 0 {
+				break
+			}
+		}
+		if end == 0 {
+			// A single word that does not fit, the chunker reports it.
+			end = len(text)
+			for _, p := range words {
+				if p > 0 {
+					end = p
+					break
+				}
+			}
+		}
+		parts = append(parts, strings.TrimSpace(text[:end]))
+		text = text[end:]
+		sentences, words = shift(sentences, end), shift(words, end)
+	}
+	return parts
+}
+
+// splitPoints returns the offsets in text of the spaces after sentences and
+// of all spaces between words, outside nested elements.
+func splitPoints(text string) (sentences, words []int) {
+	depth := 0
+	offset := 0
+	for offset < len(text) {
+		rest := text[offset:]
+		if rest[0] == '<' {
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			t := rest[:end+1]
+			switch {
+			case strings.HasPrefix(t, "</"):
+				if !strings.HasPrefix(t, "</break") {
+					depth--
+				}
+			case strings.HasPrefix(t, "<break"), strings.HasSuffix(t, "/>"):
+			default:
+				depth++
+			}
+			offset += end + 1
+			continue
+		}
+		end := strings.IndexAny(rest, "< \t\n\r")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			if depth == 0 && offset > 0 {
+				words = append(words, offset)
+				if endsSentence(html.UnescapeString(lastWord(text[:offset]))) {
+					sentences = append(sentences, offset)
+				}
+			}
+			offset++
+			continue
+		}
+		offset += end
+	}
+	return sentences, words
+}
+
+func lastWord(text string) string {
+	text = tag.ReplaceAllString(text, "")
+	return text[strings.LastIndexAny(text, " \t\n\r")+1:]
+}
+
+// shift moves offsets back by n, dropping the ones before n.
+func shift(offsets []int, n int) []int {
+	var shifted []int
+	for _, o := range offsets {
+		if o > n {
+			shifted = append(shifted, o-n)
+		}
+	}
+	return shifted
+}
+
+func hasLetterOrDigit(word string) bool {
+	return strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+// startsSentence reports whether the first letter or digit of a word is
+// an upper case letter or a digit.
+func startsSentence(word string) bool {
+	i := strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) })
+	if i < 0 {
+		return false
+	}
+	r := []rune(word[i:])[0]
+	return unicode.IsUpper(r) || unicode.IsDigit(r)
+}
+
+// endsSentence reports whether a word ends with a full stop, question mark
+// or exclamation mark, possibly followed by closing quotes or parentheses.
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, `"')]”’`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "!")
+}
@@ -0,0 +1,396 @@
+package ssmltext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/langdetect"
+	"golang.org/x/net/html"
+)
+
+// Tables with more data rows or columns than this are summarized instead of
+// being read out row by row.
+const (
+	maxTableRows    = 12
+	maxTableColumns = 6
+)
+
+// renderer turns html elements into speakable paragraphs and collects them
+// into chunks.
+type renderer struct {
+	opts       Options
+	chunks     chunker
+	paragraphs int
+	links      []Link
+	footnotes  footnotes
+	chapters   []Chapter
+}
+
+func newRenderer(opts Options, root *goquery.Selection) *renderer {
+	r := &renderer{
+		opts:   opts,
+		chunks: chunker{maxChunkChars: opts.MaxChunkChars},
+	}
+	r.footnotes = extractFootnotes(root)
+	return r
+}
+
+// addParagraph adds a paragraph to the chunks and records the position of the
+// links that are read in it. With marks, a paragraph that does not fit in a
+// chunk is split into paragraphs that do.
+func (r *renderer) addParagraph(text string, voice string) {
+	r.addBoundaryPause(voice)
+	parts := []string{text}
+	if r.opts.Marks != MarksNone {
+		parts = r.splitForMarks(text)
+	}
+	for i, part := range parts {
+		part, links := extractLinkMarkers(part)
+		plain := plainText(part)
+		part, marks := insertMarks(part, r.paragraphs, r.opts.Marks)
+		ssml := paragraph(part, r.opts.paragraphPause())
+		if i < len(parts)-1 {
+			ssml = "<p>" + part + "</p>"
+		}
+		chunk := r.chunks.add(ssml, voice, &Paragraph{Index: r.paragraphs, Text: plain, Pause: breakDuration(ssml), Marks: marks})
+		for _, i := range links {
+			if r.links[i].read {
+				continue
+			}
+			r.links[i].Chunk = chunk
+			r.links[i].Paragraph = r.paragraphs
+			r.links[i].read = true
+		}
+		r.paragraphs++
+	}
+}
+
+// endSection reads the footnotes that were referenced since the previous
+// section when footnotes are read at the end of every section.
+func (r *renderer) endSection() {
+	if r.opts.Footnotes == FootnotesEndOfSection {
+		r.readFootnotes()
+	}
+}
+
+func (r *renderer) document() (*Document, error) {
+	if r.opts.Footnotes != FootnotesDrop {
+		r.readFootnotes()
+	}
+	chunks, err := r.chunks.result()
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Chunks: chunks, Chapters: r.chaptersOf(chunks)}
+	for _, l := range r.links {
+		if l.read {
+			doc.Links = append(doc.Links, l)
+		}
+	}
+	return doc, nil
+}
+
+// text returns the speakable text of s, applying the link and footnote
+// policies to the inline elements it contains.
+func (r *renderer) text(s *goquery.Selection) string {
+	var b strings.Builder
+	for _, n := range s.Nodes {
+		r.writeText(&b, n)
+	}
+	return b.String()
+}
+
+func (r *renderer) writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+		if isFootnoteMarker(n) {
+			r.referenceFootnote(n)
+			return
+		}
+		switch n.Data {
+		case "a":
+			r.writeLink(b, n)
+			return
+		case "pre":
+			b.WriteString(strings.Join(r.code(n), " "))
+			return
+		case "code", "kbd":
+			r.writeInlineCode(b, n)
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.writeText(b, c)
+	}
+}
+
+// renderBlock renders a block level element as a list of speakable paragraph
+// texts. An empty result means that the element has nothing to say.
+func (r *renderer) renderBlock(s *goquery.Selection) []string {
+	switch goquery.NodeName(s) {
+	case "ul", "ol":
+		return r.renderList(s)
+	case "blockquote":
+		return r.renderBlockquote(s)
+	case "dl":
+		return r.renderDefinitionList(s)
+	case "table":
+		return r.renderTable(s)
+	case "pre":
+		return r.renderCode(s)
+	}
+	return r.renderParagraph(s)
+}
+
+// voiceOf returns the voice that block s is assigned to by a data-voice
+// attribute, an enclosing <voice> element, its language or the voice rules.
+func (r *renderer) voiceOf(s *goquery.Selection) string {
+	if voice, ok := s.Closest("[data-voice]").Attr("data-voice"); ok {
+		return voice
+	}
+	if voice, ok := s.Closest("voice[name]").Attr("name"); ok {
+		return voice
+	}
+	if voice := r.languageVoice(s); len(voice) > 0 {
+		return voice
+	}
+	for _, rule := range r.opts.VoiceRules {
+		if s.Closest(rule.Selector).Length() > 0 {
+			return rule.Voice
+		}
+	}
+	return ""
+}
+
+// languageVoice returns the voice for the language of block s when that is
+// not the default language. A lang attribute on the document root only
+// declares the language of the page as a whole, so blocks below it are still
+// detected.
+func (r *renderer) languageVoice(s *goquery.Selection) string {
+	if len(r.opts.LanguageVoices) == 0 {
+		return ""
+	}
+	language, ok := s.Closest("[lang]").Not("html, body").Attr("lang")
+	if ok {
+		language = strings.ToLower(strings.SplitN(language, "-", 2)[0])
+	} else {
+		language = langdetect.DetectOther(s.Text(), r.opts.Language)
+	}
+	if len(language) == 0 || language == r.opts.Language {
+		return ""
+	}
+	return r.opts.LanguageVoices[language]
+}
+
+func isBlock(s *goquery.Selection) bool {
+	return s.Is(blockSelector)
+}
+
+func (r *renderer) renderParagraph(s *goquery.Selection) []string {
+	text := r.text(s)
+	if len(strings.TrimSpace(text)) == 0 {
+		return nil
+	}
+	return []string{addBreaks(text)}
+}
+
+// renderList announces the number of items and reads every item as a
+// paragraph of its own. Items of an <ol> are numbered, nested lists are read
+// directly after the item that contains them.
+func (r *renderer) renderList(s *goquery.Selection) []string {
+	items := s.ChildrenFiltered("li")
+	if items.Length() == 0 {
+		return nil
+	}
+	numbered := goquery.NodeName(s) == "ol"
+	start := 1
+	if v, ok := s.Attr("start"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			start = n
+		}
+	}
+	kind := "list"
+	if numbered {
+		kind = "numbered list"
+	}
+	paragraphs := []string{fmt.Sprintf("A %s of %s.", kind, plural(items.Length(), "item"))}
+	items.Each(func(i int, item *goquery.Selection) {
+		nested := outermost(item, "ul, ol")
+		text := normalizeSpace(r.textWithout(item, "ul, ol"))
+		if numbered {
+			text = fmt.Sprintf("%d. %s", start+i, text)
+		}
+		if len(text) > 0 {
+			paragraphs = append(paragraphs, addBreaks(text))
+		}
+		nested.Each(func(_ int, list *goquery.Selection) {
+			paragraphs = append(paragraphs, r.renderList(list)...)
+		})
+	})
+	return append(paragraphs, "End of list.")
+}
+
+// renderBlockquote introduces the quoted blocks with "Quote:" and closes them
+// with "End quote." so listeners can tell the quote apart from the article.
+func (r *renderer) renderBlockquote(s *goquery.Selection) []string {
+	var paragraphs []string
+	blocks := outermost(s, blockSelector)
+	if blocks.Length() == 0 {
+		paragraphs = r.renderParagraph(s)
+	}
+	blocks.Each(func(_ int, b *goquery.Selection) {
+		paragraphs = append(paragraphs, r.renderBlock(b)...)
+	})
+	if len(paragraphs) == 0 {
+		return nil
+	}
+	paragraphs[0] = "Quote:" + br(300) + " " + paragraphs[0]
+	paragraphs[len(paragraphs)-1] += " End quote."
+	return paragraphs
+}
+
+// renderDefinitionList reads every term followed by its definitions.
+func (r *renderer) renderDefinitionList(s *goquery.Selection) []string {
+	var paragraphs []string
+	var term string
+	outermost(s, "dt, dd").Each(func(_ int, item *goquery.Selection) {
+		text := addBreaks(normalizeSpace(r.text(item)))
+		if len(text) == 0 {
+			return
+		}
+		if goquery.NodeName(item) == "dt" {
+			if len(term) > 0 {
+				paragraphs = append(paragraphs, term)
+			}
+			term = text
+			return
+		}
+		if len(term) > 0 {
+			text = term + ":" + br(300) + " " + text
+			term = ""
+		}
+		paragraphs = append(paragraphs, text)
+	})
+	if len(term) > 0 {
+		paragraphs = append(paragraphs, term)
+	}
+	return paragraphs
+}
+
+// renderTable reads a table row by row, labelling every cell with the header
+// of its column. Large tables are summarized by their dimensions and column
+// headers.
+func (r *renderer) renderTable(s *goquery.Selection) []string {
+	// The cells are only rendered once it is known whether the rows are read,
+	// so that links and footnotes in rows that are not read are left out.
+	var headerCells *goquery.Selection
+	var rowCells []*goquery.Selection
+	columns := 0
+	outermost(s, "tr").Each(func(_ int, tr *goquery.Selection) {
+		cells := tr.ChildrenFiltered("th, td")
+		if cells.Length() == 0 {
+			return
+		}
+		if cells.Length() > columns {
+			columns = cells.Length()
+		}
+		if headerCells == nil && len(rowCells) == 0 && cells.Filter("th").Length() == cells.Length() {
+			headerCells = cells
+			return
+		}
+		rowCells = append(rowCells, cells)
+	})
+	if len(rowCells) == 0 {
+		return nil
+	}
+
+	intro := "A table"
+	if caption := normalizeSpace(r.text(s.ChildrenFiltered("caption").First())); len(caption) > 0 {
+		intro += " titled " + caption
+	}
+	intro += fmt.Sprintf(" with %s and %s", plural(len(rowCells), "row"), plural(columns, "column"))
+	headers := r.cellTexts(headerCells)
+	if len(rowCells) > maxTableRows || columns > maxTableColumns {
+		summary := intro + " is not read out."
+		if len(headers) > 0 {
+			summary += " Its columns are " + strings.Join(headers, ", ") + "."
+		}
+		return []string{addBreaks(summary)}
+	}
+
+	paragraphs := []string{addBreaks(intro + ".")}
+	for i, row := range rowCells {
+		var cells []string
+		for j, cell := range r.cellTexts(row) {
+			if len(cell) == 0 {
+				continue
+			}
+			if j < len(headers) && len(headers[j]) > 0 {
+				cell = headers[j] + ": " + cell
+			}
+			cells = append(cells, cell)
+		}
+		paragraphs = append(paragraphs, addBreaks(fmt.Sprintf("Row %d. %s.", i+1, strings.Join(cells, "; "))))
+	}
+	return paragraphs
+}
+
+// cellTexts renders the text of every cell, cells may be nil.
+func (r *renderer) cellTexts(cells *goquery.Selection) []string {
+	if cells == nil {
+		return nil
+	}
+	var texts []string
+	cells.Each(func(_ int, cell *goquery.Selection) {
+		texts = append(texts, normalizeSpace(r.text(cell)))
+	})
+	return texts
+}
+
+// outermost returns the descendants of s that match selector and are not
+// nested inside another match below s.
+func outermost(s *goquery.Selection, selector string) *goquery.Selection {
+	return s.Find(selector).FilterFunction(func(_ int, d *goquery.Selection) bool {
+		return d.ParentsUntilSelection(s).Filter(selector).Length() == 0
+	})
+}
+
+// textWithout returns the text of s, leaving out the descendants that match
+// selector.
+func (r *renderer) textWithout(s *goquery.Selection, selector string) string {
+	c := s.Clone()
+	c.Find(selector).Remove()
+	return r.text(c)
+}
+
+func firstNode(s *goquery.Selection) *html.Node {
+	if len(s.Nodes) == 0 {
+		return nil
+	}
+	return s.Nodes[0]
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
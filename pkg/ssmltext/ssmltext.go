@@ -3,106 +3,314 @@ package ssmltext
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/alexandervantrijffel/goutil/errorcheck"
 	"github.com/alexandervantrijffel/goutil/logging"
+	"golang.org/x/net/html"
 )
 
+// blockSelector matches the html elements that are rendered as speakable
+// blocks. Blocks nested inside another block are rendered by their parent.
+const blockSelector = "p, ul, ol, blockquote, dl, table, pre"
+
+// headingSelector matches the headings that start a new section.
+const headingSelector = "h1, h2, h3, h4, h5, h6"
+
+// Options control how html and SSML input is turned into chunks.
+type Options struct {
+	MaxChunkChars int
+	Links         LinkPolicy
+	Footnotes     FootnotePolicy
+	Code          CodePolicy
+	// VoiceRules assign voices to the blocks that match their selector or
+	// are nested in an element that does. The first matching rule wins, a
+	// data-voice attribute or <voice name="..."> element takes precedence.
+	VoiceRules []VoiceRule
+	// Language is the ISO 639-1 code of the language of the default voice.
+	// Blocks in another language, as declared by a lang attribute or
+	// detected from their text, are read with the voice that LanguageVoices
+	// has for that language. Without such a voice the block falls back to
+	// the voice rules and the default voice.
+	Language       string
+	LanguageVoices map[string]string
+	// Marks sets where <mark> elements are inserted in the paragraphs.
+	Marks MarkPolicy
+	// Chapters sets where html documents are divided into chapters.
+	Chapters ChapterPolicy
+	// ParagraphPause is the break after every paragraph, 800ms when zero.
+	ParagraphPause time.Duration
+	// SectionPause and StoryPause are the pauses before a section or story
+	// that starts inside a chunk, at least the pause after a paragraph.
+	SectionPause time.Duration
+	StoryPause   time.Duration
+	// ExpandAudio puts the <audio> elements of SSML input in chunks of their
+	// own, to be spliced in locally instead of being fetched by the API.
+	ExpandAudio bool
+}
+
+// VoiceRule assigns a voice to the blocks matching a css selector, like
+// "blockquote" or ".interviewer".
+type VoiceRule struct {
+	Selector string
+	Voice    string
+}
+
+// Chunk is a piece of SSML that is synthesized with a single request.
+type Chunk struct {
+	Ssml string
+	// Voice is the name of the voice to synthesize the chunk with. Empty
+	// means the default voice.
+	Voice string
+	// Paragraphs are the paragraphs that the chunk reads, in order.
+	Paragraphs []Paragraph
+	// Starts is the boundary in the document before the first paragraph of
+	// the chunk.
+	Starts Boundary
+	// Audio is set for a chunk that plays an <audio> element instead of
+	// being synthesized, Ssml then holds its fallback content.
+	Audio *Audio
+}
+
+// Paragraph is a paragraph of the document as it is read in a chunk.
+type Paragraph struct {
+	// Index is the position of the paragraph in the whole document.
+	Index int
+	// Text is the plain text that is spoken.
+	Text string
+	// Pause is the total time of the breaks in and after the paragraph.
+	Pause time.Duration
+	// Marks are the <mark> elements in the paragraph, in order.
+	Marks []Mark
+}
+
+// Document is the result of converting html or SSML input to speech chunks.
+type Document struct {
+	Chunks []Chunk
+	// Links holds the links collected with the LinksCollect policy, in the
+	// order in which they are read.
+	Links []Link
+	// Chapters holds the chapters of html documents as divided by the
+	// chapter policy, in order.
+	Chapters []Chapter
+}
+
 func MakeChunks(ssml string, maxChunkChars int) ([]string, error) {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(ssml))
-	errorcheck.CheckLogFatalf(err, "goquery failed to parse text. %s", ssml)
+	doc, err := MakeDocument(ssml, Options{MaxChunkChars: maxChunkChars})
+	if err != nil {
+		return nil, err
+	}
+	var chunks []string
+	for _, c := range doc.Chunks {
+		chunks = append(chunks, c.Ssml)
+	}
+	return chunks, nil
+}
 
+// MakeDocument converts html or SSML input to chunks of SSML that can be
+// synthesized, applying the policies in opts.
+func MakeDocument(input string, opts Options) (*Document, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(input))
+	errorcheck.CheckLogFatalf(err, "goquery failed to parse text. %s", input)
+
+	r := newRenderer(opts, doc.Selection)
 	speak := doc.Find("speak")
 	if len(speak.Nodes) > 0 {
-		return processSsml(speak, maxChunkChars)
+		return r.processSsml(speak)
 	}
 
-	paragraphs := doc.Find("p")
-	logging.Infof("Found %d paragraps", len(paragraphs.Nodes))
-	if len(paragraphs.Nodes) > 0 {
-		return processParagraphs(paragraphs, maxChunkChars)
+	blocks := outermost(doc.Selection, blockSelector+", "+headingSelector)
+	logging.Infof("Found %d blocks", len(blocks.Nodes))
+	if len(blocks.Not(headingSelector).Nodes) > 0 {
+		return r.processBlocks(blocks)
 	}
 	return nil, errorcheck.LogAndWrapAsError("No <speak> or <p> elements found. Processing of plain text is not supported")
 }
-func processParagraphs(paragraphs *goquery.Selection, maxChunkChars int) ([]string, error) {
-	logging.Info("Processing paragraphs")
-	var chunks []string
-	var chunkHtml string
-	var lastErr error
-	paragraphs.Each(func(i int, s *goquery.Selection) {
-		text := s.Text()
-		if len(strings.TrimSpace(text)) == 0 {
-			ohtml, _ := goquery.OuterHtml(s)
-			logging.Debugf("Skipping paragraph without text. %s", ohtml)
+func (r *renderer) processBlocks(blocks *goquery.Selection) (*Document, error) {
+	logging.Info("Processing html blocks")
+	var section, story *html.Node
+	blocks.Each(func(i int, s *goquery.Selection) {
+		if s.Is(headingSelector) {
+			r.endSection()
+			r.markBoundary(BoundarySection)
+			r.chapterBoundary(s, section, story)
 			return
 		}
-		html := fmt.Sprintf("<p>%s</p>%s", addBreaks(s.Text()), br(800))
-		if len(html) > maxChunkChars {
-			lastErr = errorcheck.LogAndWrapAsError("A single paragraph has more chars than the maximum per chunk of %d chars. Cannot process: %s",
-				maxChunkChars, html)
+		if container := firstNode(s.Closest("section, article")); container != section {
+			r.endSection()
+			r.markBoundary(BoundarySection)
+		}
+		if container := firstNode(s.Closest("article")); container != story && r.paragraphs > 0 {
+			r.markBoundary(BoundaryStory)
+		}
+		r.chapterBoundary(s, section, story)
+		section = firstNode(s.Closest("section, article"))
+		story = firstNode(s.Closest("article"))
+		paragraphs := r.renderBlock(s)
+		if len(paragraphs) == 0 {
+			ohtml, _ := goquery.OuterHtml(s)
+			logging.Debugf("Skipping block without text. %s", ohtml)
 			return
 		}
-		if len(chunkHtml)+len(html) > maxChunkChars {
-			chunks = append(chunks, addSpeak(chunkHtml))
-			chunkHtml = ""
+		voice := r.voiceOf(s)
+		for _, text := range paragraphs {
+			r.addParagraph(text, voice)
 		}
-		chunkHtml += html
 	})
-	chunks = append(chunks, addSpeak(chunkHtml))
-	if lastErr != nil {
-		return nil, lastErr
-	}
-	logging.Infof("Have %d chunks", len(chunks))
-	return chunks, nil
+	return r.document()
 }
 
-func processSsml(speak *goquery.Selection, maxChunkChars int) ([]string, error) {
+func (r *renderer) processSsml(speak *goquery.Selection) (*Document, error) {
 	logging.Info("Processing SSML text")
 	children := speak.Children()
-	var chunks []string
 	if len(children.Nodes) > 0 {
-		var chunkHtml string
-		var lastErr error
-		children.Each(func(i int, s *goquery.Selection) {
-			if html, err := goquery.OuterHtml(s); err != nil {
-				lastErr = errorcheck.CheckLogf(err, "Failed to retrieve html of %s", s.Text())
-			} else {
-				if strings.ToLower(goquery.NodeName(s)) == "p" {
-					html = fmt.Sprintf("<p>%s</p>%s", addBreaks(s.Text()), br(800))
-					logging.Info("HTML", html)
-				}
-				if len(html) > maxChunkChars {
-					lastErr = errorcheck.LogAndWrapAsError("A single html element has more chars than the maximum per chunk of %d chars. Cannot process: %s",
-						maxChunkChars, html)
-					return
-				}
-				if len(chunkHtml)+len(html) > maxChunkChars {
-					chunks = append(chunks, addSpeak(chunkHtml))
-					chunkHtml = ""
-				}
-				chunkHtml += html
-			}
-		})
-		chunks = append(chunks, addSpeak(chunkHtml))
-		if lastErr != nil {
-			return nil, lastErr
-		}
-		logging.Infof("Have %d chunks", len(chunks))
-		return chunks, nil
+		r.processSsmlElements(children, "")
+		return r.document()
 	}
 	err := errors.New("No html children found in ssml")
 	return nil, err
 }
 
+// processSsmlElements adds SSML elements to the chunks. The children of a
+// <voice> element are synthesized with the voice it names, other elements
+// that are not a block keep the voice of the content before them.
+func (r *renderer) processSsmlElements(elements *goquery.Selection, voice string) {
+	elements.Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "voice" {
+			r.processSsmlElements(s.Children(), r.voiceOf(s))
+			return
+		}
+		if r.opts.ExpandAudio && goquery.NodeName(s) == "audio" && len(s.AttrOr("src", "")) > 0 {
+			r.addAudio(s, voice)
+			return
+		}
+		if isBlock(s) {
+			voice = r.voiceOf(s)
+			for _, text := range r.renderBlock(s) {
+				r.addParagraph(text, voice)
+			}
+			return
+		}
+		if html, err := goquery.OuterHtml(s); err != nil {
+			r.chunks.lastErr = errorcheck.CheckLogf(err, "Failed to retrieve html of %s", s.Text())
+		} else {
+			r.chunks.add(html, voice, nil)
+		}
+	})
+}
+
+// chunker collects html fragments into <speak> chunks of at most
+// maxChunkChars characters. Fragments for different voices never share a
+// chunk.
+type chunker struct {
+	maxChunkChars int
+	chunks        []Chunk
+	current       Chunk
+	chunkHtml     string
+	lastErr       error
+	// boundary is the boundary before the next paragraph.
+	boundary Boundary
+}
+
+// add appends html to the current chunk, starting a new chunk when it does
+// not fit anymore or is for another voice. It returns the index of the chunk
+// that html was added to. Fragments that are not a paragraph, like a
+// <break>, add their pause to the paragraph before them.
+func (c *chunker) add(html string, voice string, p *Paragraph) int {
+	if len(html) > c.maxChunkChars {
+		c.lastErr = errorcheck.LogAndWrapAsError("A single html element has more chars than the maximum per chunk of %d chars. Cannot process: %s",
+			c.maxChunkChars, html)
+		return len(c.chunks)
+	}
+	if len(c.chunkHtml) > 0 && (len(c.chunkHtml)+len(html) > c.maxChunkChars || voice != c.current.Voice) {
+		c.flush()
+	}
+	if len(c.chunkHtml) == 0 {
+		c.current.Starts = c.boundary
+	}
+	c.chunkHtml += html
+	c.current.Voice = voice
+	if p != nil {
+		c.current.Paragraphs = append(c.current.Paragraphs, *p)
+		c.boundary = BoundaryParagraph
+	} else if n := len(c.current.Paragraphs); n > 0 {
+		c.current.Paragraphs[n-1].Pause += breakDuration(html)
+	}
+	return len(c.chunks)
+}
+
+// addAudio adds a chunk that plays audio, after the content so far.
+func (c *chunker) addAudio(a *Audio, fallback, voice string) int {
+	if len(c.chunkHtml) > 0 {
+		c.flush()
+	}
+	c.chunks = append(c.chunks, Chunk{Ssml: addSpeak(fallback), Voice: voice, Audio: a, Starts: c.boundary})
+	return len(c.chunks) - 1
+}
+
+func (c *chunker) flush() {
+	c.current.Ssml = addSpeak(c.chunkHtml)
+	c.chunks = append(c.chunks, c.current)
+	c.current = Chunk{}
+	c.chunkHtml = ""
+}
+
+func (c *chunker) result() ([]Chunk, error) {
+	if len(c.chunkHtml) > 0 || len(c.chunks) == 0 {
+		c.flush()
+	}
+	if c.lastErr != nil {
+		return nil, c.lastErr
+	}
+	logging.Infof("Have %d chunks", len(c.chunks))
+	return c.chunks, nil
+}
+
+var (
+	breakTime = regexp.MustCompile(`<break[^>]*\stime="(\d+(?:\.\d+)?)(ms|s)"`)
+	tag       = regexp.MustCompile(`<[^>]*>`)
+	// pauseMark matches the commas and semicolons that get a short pause,
+	// and escaped characters so that their semicolons are left alone.
+	pauseMark = regexp.MustCompile(`&#?\w+;|[,;]`)
+)
+
+// breakDuration returns the total time of the <break> elements in html.
+func breakDuration(html string) time.Duration {
+	var total time.Duration
+	for _, m := range breakTime.FindAllStringSubmatch(html, -1) {
+		value, _ := strconv.ParseFloat(m[1], 64)
+		if m[2] == "s" {
+			value *= 1000
+		}
+		total += time.Duration(value * float64(time.Millisecond))
+	}
+	return total
+}
+
+// plainText returns the text of an SSML fragment without its markup.
+func plainText(ssml string) string {
+	return normalizeSpace(html.UnescapeString(tag.ReplaceAllString(ssml, " ")))
+}
+
 func br(ms int) string {
 	return fmt.Sprintf(`<break time="%dms"></break>`, ms)
 }
 
 func addBreaks(text string) string {
-	a := strings.Replace(text, ",", ","+br(200), -1)
-	return strings.Replace(a, ";", ","+br(200), -1)
+	return pauseMark.ReplaceAllStringFunc(text, func(m string) string {
+		if m[0] == '&' {
+			return m
+		}
+		return "," + br(200)
+	})
+}
+
+func paragraph(text string, pause time.Duration) string {
+	return fmt.Sprintf("<p>%s</p>%s", text, br(int(pause/time.Millisecond)))
 }
 
 func addSpeak(text string) string {
@@ -3,6 +3,7 @@ package ssmltext
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/alexandervantrijffel/goutil/logging"
@@ -275,3 +276,365 @@ they work, in the hope that we will all benefit from their insights.</p>
 	_, _ = MakeChunks(html, 1800)
 	t.Fatal("no")
 }
+
+func TestListsAreAnnouncedAndNumbered(t *testing.T) {
+	chunks, err := MakeChunks(`<ol start="3"><li>Install Go</li><li>Build the tool<ul><li>on Linux</li><li>on macOS</li></ul></li></ol>`, 5000)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(chunks))
+	assert.Contains(t, chunks[0], "<p>A numbered list of 2 items.</p>")
+	assert.Contains(t, chunks[0], "<p>3. Install Go</p>")
+	assert.Contains(t, chunks[0], "<p>4. Build the tool</p>")
+	assert.Contains(t, chunks[0], "<p>A list of 2 items.</p>")
+	assert.Contains(t, chunks[0], "<p>on macOS</p>")
+}
+
+func TestTextIsEscaped(t *testing.T) {
+	chunks, err := MakeChunks(`<ul><li>AT&amp;T &lt;div&gt;; done</li></ul>`, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], `<p>AT&amp;T &lt;div&gt;,<break time="200ms"></break> done</p>`)
+}
+
+func TestBlockquoteIsIntroduced(t *testing.T) {
+	chunks, err := MakeChunks(`<p>He said</p><blockquote><p>Ship it.</p><p>Then fix it.</p></blockquote>`, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], `<p>Quote:<break time="300ms"></break> Ship it.</p>`)
+	assert.Contains(t, chunks[0], "<p>Then fix it. End quote.</p>")
+}
+
+func TestDefinitionList(t *testing.T) {
+	chunks, err := MakeChunks(`<dl><dt>SSML</dt><dd>Speech Synthesis Markup Language</dd></dl>`, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], `<p>SSML:<break time="300ms"></break> Speech Synthesis Markup Language</p>`)
+}
+
+func TestTableIsReadWithHeaderLabels(t *testing.T) {
+	chunks, err := MakeChunks(`<table><tr><th>Language</th><th>Year</th></tr><tr><td>Go</td><td>2009</td></tr></table>`, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], "<p>A table with 1 row and 2 columns.</p>")
+	assert.Contains(t, chunks[0], `<p>Row 1. Language: Go,<break time="200ms"></break> Year: 2009.</p>`)
+}
+
+func TestNestedDefinitionListIsReadOnce(t *testing.T) {
+	chunks, err := MakeChunks(`<dl><dt>Go</dt><dd>A language<dl><dt>gc</dt><dd>The compiler</dd></dl></dd></dl>`, 5000)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(chunks[0], "The compiler"))
+	assert.Equal(t, 1, strings.Count(chunks[0], "gc"))
+}
+
+func TestNestedTableIsReadOnce(t *testing.T) {
+	chunks, err := MakeChunks(`<table><tr><th>Name</th><th>Parts</th></tr><tr><td>Kit</td><td><table><caption>Inner</caption><tr><td>bolt</td></tr></table></td></tr></table>`, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], "<p>A table with 1 row and 2 columns.</p>")
+	assert.Equal(t, 1, strings.Count(chunks[0], "bolt"))
+	assert.NotContains(t, chunks[0], "titled")
+}
+
+func TestLargeTableIsSummarized(t *testing.T) {
+	table := "<table><tr><th>N</th></tr>" + strings.Repeat("<tr><td>1</td></tr>", maxTableRows+1) + "</table>"
+	chunks, err := MakeChunks(table, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], "A table with 13 rows and 1 column is not read out. Its columns are N.")
+	assert.NotContains(t, chunks[0], "Row 1.")
+}
+
+func TestUnreadTableRowsQueueNoLinksOrFootnotes(t *testing.T) {
+	table := `<table><tr><th>N</th></tr><tr><td>See <a href="https://golang.org">Go</a><sup><a href="#fn1">1</a></sup></td></tr>` +
+		strings.Repeat("<tr><td>1</td></tr>", maxTableRows) + `</table><ol class="footnotes"><li id="fn1">The note.</li></ol>`
+	doc, err := MakeDocument(table, Options{MaxChunkChars: 5000, Links: LinksCollect, Footnotes: FootnotesEndOfArticle})
+	assert.Nil(t, err)
+	assert.Empty(t, doc.Links)
+	assert.NotContains(t, doc.Chunks[0].Ssml, "The note.")
+}
+
+func TestLinksAndFootnotesAreEscaped(t *testing.T) {
+	doc, err := MakeDocument(`<p>Ask <a href="https://example.com">Q&amp;A</a><sup><a href="#fn1">1</a></sup>.</p><ol class="footnotes"><li id="fn1">Tom &amp; Jerry &lt;3</li></ol>`,
+		Options{MaxChunkChars: 5000, Links: LinksCollect, Footnotes: FootnotesEndOfArticle})
+	assert.Nil(t, err)
+	assert.Equal(t, "Q&A", doc.Links[0].Text)
+	assert.Contains(t, doc.Chunks[0].Ssml, "<p>Ask Q&amp;A.</p>")
+	assert.Contains(t, doc.Chunks[0].Ssml, "Tom &amp; Jerry &lt;3</p>")
+}
+
+func TestLinksAreCollectedWithTheirPosition(t *testing.T) {
+	doc, err := MakeDocument(`<p>Intro</p><p>Read <a href="https://golang.org">the Go site</a> and <a href="#top">skip this</a>.</p>`,
+		Options{MaxChunkChars: 5000, Links: LinksCollect})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(doc.Links))
+	assert.Equal(t, Link{Text: "the Go site", URL: "https://golang.org", Chunk: 0, Paragraph: 1, read: true}, doc.Links[0])
+	assert.Contains(t, doc.Chunks[0].Ssml, "<p>Read the Go site and skip this.</p>")
+}
+
+func TestLinksAreAnnounced(t *testing.T) {
+	doc, err := MakeDocument(`<p>See <a href="https://golang.org">Go</a>.</p>`, Options{MaxChunkChars: 5000, Links: LinksAnnounce})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, "<p>See Go (link).</p>")
+	assert.Empty(t, doc.Links)
+}
+
+const footnotesHtml = `<section><p>First claim<sup id="fnref1"><a href="#fn1">1</a></sup> and a citation<sup>[2]</sup>.</p></section>
+<section><p>Second section.</p></section>
+<div class="footnotes"><ol><li id="fn1"><p>The source of the claim. <a href="#fnref1">↩</a></p></li></ol></div>`
+
+func TestFootnoteMarkersAreDropped(t *testing.T) {
+	chunks, err := MakeChunks(footnotesHtml, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], "<p>First claim and a citation.</p>")
+	assert.NotContains(t, chunks[0], "source of the claim")
+}
+
+func TestBracketedNumbersInProseAreKept(t *testing.T) {
+	chunks, err := MakeChunks(`<p>Read array[0] first, then option [1].</p>`, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], `<p>Read array[0] first,<break time="200ms"></break> then option [1].</p>`)
+}
+
+func TestFootnotesAreReadAtTheEndOfTheSection(t *testing.T) {
+	doc, err := MakeDocument(footnotesHtml, Options{MaxChunkChars: 5000, Footnotes: FootnotesEndOfSection})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, `<p>First claim and a citation.</p><break time="800ms"></break><p>Footnote 1:<break time="300ms"></break> The source of the claim.</p><break time="800ms"></break><p>Second section.</p>`)
+}
+
+const codeHtml = `<p>Call <code>makeChunks(ssml, max)</code> first.</p><pre><code class="language-go">func main() {
+	chunks, err := ssmltext.MakeChunks(content, 5000)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+</code></pre>`
+
+func TestCodeIsSkippedWithACue(t *testing.T) {
+	chunks, err := MakeChunks(codeHtml, 5000)
+	assert.Nil(t, err)
+	assert.Contains(t, chunks[0], "<p>Call make Chunks open paren ssml comma max close paren first.</p>")
+	assert.Contains(t, chunks[0], "<p>Go code sample omitted, 6 lines.</p>")
+	assert.NotContains(t, chunks[0], "{")
+}
+
+func TestCodeIsSummarized(t *testing.T) {
+	doc, err := MakeDocument(codeHtml, Options{MaxChunkChars: 5000, Code: CodeSummarize})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, "<p>A Go code sample of 6 lines that defines main.</p>")
+}
+
+func TestShortCodeIsRead(t *testing.T) {
+	doc, err := MakeDocument(`<pre>x := map[string]int{}</pre>`, Options{MaxChunkChars: 5000, Code: CodeRead})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, "<p>x colon equals map open bracket string close bracket int open brace close brace</p>")
+}
+
+func TestLongInlineCodeIsHandledLikeABlock(t *testing.T) {
+	code := "<code>" + strings.Repeat("x = y + 1; ", 30) + "</code>"
+	doc, err := MakeDocument("<p>Run "+code+" now.</p>", Options{MaxChunkChars: 5000, Code: CodeRead})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, "<p>Run A code sample of 1 line now.</p>")
+	doc, err = MakeDocument("<p>Run "+code+" now.</p>", Options{MaxChunkChars: 5000})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, `<p>Run Code sample omitted,<break time="200ms"></break> 1 line now.</p>`)
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	assert.Equal(t, "max chunk chars", splitIdentifier("max_chunk_chars"))
+	assert.Equal(t, "Parse HTML Document", splitIdentifier("ParseHTMLDocument"))
+}
+
+func TestChunksNeverMixVoices(t *testing.T) {
+	doc, err := MakeDocument(`<p>Question one?</p><p data-voice="en-US-Wavenet-F">Answer one.</p><blockquote>Quoted.</blockquote><p>Question two?</p>`,
+		Options{MaxChunkChars: 5000, VoiceRules: []VoiceRule{{Selector: "blockquote", Voice: "en-GB-Wavenet-B"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(doc.Chunks))
+	assert.Equal(t, "", doc.Chunks[0].Voice)
+	assert.Equal(t, "en-US-Wavenet-F", doc.Chunks[1].Voice)
+	assert.Equal(t, "en-GB-Wavenet-B", doc.Chunks[2].Voice)
+	assert.Contains(t, doc.Chunks[2].Ssml, "Quoted. End quote.")
+	assert.Equal(t, "", doc.Chunks[3].Voice)
+}
+
+func TestSsmlVoiceElement(t *testing.T) {
+	doc, err := MakeDocument(`<speak><p>Host.</p><voice name="en-US-Wavenet-C"><p>Guest.</p><break time="1s"></break></voice><p>Host again.</p></speak>`,
+		Options{MaxChunkChars: 5000})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(doc.Chunks))
+	assert.Equal(t, "en-US-Wavenet-C", doc.Chunks[1].Voice)
+	assert.Contains(t, doc.Chunks[1].Ssml, `<break time="1s"></break>`)
+	assert.NotContains(t, doc.Chunks[1].Ssml, "voice")
+}
+
+func TestParagraphsInOtherLanguagesUseTheirVoice(t *testing.T) {
+	doc, err := MakeDocument(`<p>The minister started his speech in German.</p>
+<blockquote>Wir haben die Zeit genommen, das Problem zu verstehen, bevor wir es lösen wollten.</blockquote>
+<p lang="fr">Merci beaucoup.</p>
+<p lang="nl">Dank je wel.</p>`,
+		Options{MaxChunkChars: 5000, Language: "en", LanguageVoices: map[string]string{"de": "de-DE-Wavenet-B", "fr": "fr-FR-Wavenet-B"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(doc.Chunks))
+	assert.Equal(t, "", doc.Chunks[0].Voice)
+	assert.Equal(t, "de-DE-Wavenet-B", doc.Chunks[1].Voice)
+	assert.Equal(t, "fr-FR-Wavenet-B", doc.Chunks[2].Voice)
+	assert.Contains(t, doc.Chunks[2].Ssml, "Merci beaucoup.")
+	assert.Equal(t, "", doc.Chunks[3].Voice)
+	assert.Contains(t, doc.Chunks[3].Ssml, "Dank je wel.")
+}
+
+func TestDocumentLanguageDoesNotDisableDetection(t *testing.T) {
+	doc, err := MakeDocument(`<html lang="en"><body><p>The minister started his speech in German.</p>
+<p>Wir haben die Zeit genommen, das Problem zu verstehen, bevor wir es lösen wollten.</p></body></html>`,
+		Options{MaxChunkChars: 5000, Language: "en", LanguageVoices: map[string]string{"de": "de-DE-Wavenet-B"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(doc.Chunks))
+	assert.Equal(t, "", doc.Chunks[0].Voice)
+	assert.Equal(t, "de-DE-Wavenet-B", doc.Chunks[1].Voice)
+}
+
+func TestTechnicalProseKeepsTheDefaultVoice(t *testing.T) {
+	doc, err := MakeDocument(`<p>Kubernetes operators reconcile desired state with actual state in a control loop.</p>
+<p>Rust borrow checker prevents data races at compile time.</p>
+<p>PostgreSQL supports transactional DDL, so migrations can be rolled back atomically.</p>`,
+		Options{MaxChunkChars: 5000, Language: "en", LanguageVoices: map[string]string{"de": "de-DE-Wavenet-B", "es": "es-ES-Wavenet-B", "fr": "fr-FR-Wavenet-B", "it": "it-IT-Wavenet-B", "nl": "nl-NL-Wavenet-B"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(doc.Chunks))
+	assert.Equal(t, "", doc.Chunks[0].Voice)
+}
+
+func TestChunksKeepTheirParagraphs(t *testing.T) {
+	doc, err := MakeDocument(`<speak><p>One, two.</p><break time="1.5s"></break><p>Three &amp; four.</p></speak>`, Options{MaxChunkChars: 5000})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(doc.Chunks))
+	assert.Equal(t, []Paragraph{
+		{Index: 0, Text: "One, two.", Pause: 2500 * time.Millisecond},
+		{Index: 1, Text: "Three & four.", Pause: 800 * time.Millisecond},
+	}, doc.Chunks[0].Paragraphs)
+}
+
+func TestSentenceMarks(t *testing.T) {
+	doc, err := MakeDocument(`<p>It works. Does it? "Yes!" it does.</p>`, Options{MaxChunkChars: 5000, Marks: MarksSentences})
+	assert.Nil(t, err)
+	assert.Equal(t, []Mark{
+		{Name: "p0.s0", Text: "It works."},
+		{Name: "p0.s1", Text: "Does it?"},
+		{Name: "p0.s2", Text: `"Yes!" it does.`},
+	}, doc.Chunks[0].Paragraphs[0].Marks)
+	assert.Contains(t, doc.Chunks[0].Ssml, `<mark name="p0.s1"></mark>Does it?`)
+	assert.Equal(t, `It works. Does it? "Yes!" it does.`, doc.Chunks[0].Paragraphs[0].Text)
+}
+
+func TestWordMarks(t *testing.T) {
+	doc, err := MakeDocument(`<p>Tom &amp; Jerry, run <code>go</code> - now</p>`, Options{MaxChunkChars: 5000, Marks: MarksWords})
+	assert.Nil(t, err)
+	var texts []string
+	for _, m := range doc.Chunks[0].Paragraphs[0].Marks {
+		texts = append(texts, m.Text)
+	}
+	assert.Equal(t, []string{"Tom &", "Jerry,", "run", "go -", "now"}, texts)
+	assert.Contains(t, doc.Chunks[0].Ssml, `<mark name="p0.w1"></mark>Jerry,<break time="200ms"></break>`)
+}
+
+func TestLongParagraphsAreSplitForMarks(t *testing.T) {
+	sentence := "The quick brown fox jumps over the lazy dog near the river bank. "
+	long := "<p>" + strings.Repeat(sentence, 20) + strings.Repeat("word ", 200) + "end.</p><p>Next.</p>"
+	doc, err := MakeDocument(long, Options{MaxChunkChars: 5000, Marks: MarksWords})
+	assert.Nil(t, err)
+	assert.True(t, len(doc.Chunks) > 1)
+	var words []string
+	names := map[string]bool{}
+	for _, c := range doc.Chunks {
+		assert.True(t, len(c.Ssml) <= 5000)
+		for _, p := range c.Paragraphs {
+			for _, m := range p.Marks {
+				words = append(words, m.Text)
+				names[m.Name] = true
+			}
+		}
+	}
+	assert.Equal(t, 20*13+200+2, len(words))
+	assert.Equal(t, len(words), len(names))
+	assert.Equal(t, "dog", words[8])
+	assert.Equal(t, "Next.", words[len(words)-1])
+	assert.True(t, strings.HasSuffix(doc.Chunks[0].Ssml, "bank.</p></speak>"))
+	assert.True(t, strings.HasPrefix(doc.Chunks[1].Ssml, `<speak><p><mark name="p1.w0"></mark>The <mark name="p1.w1"></mark>quick`))
+}
+
+const storiesHtml = `<p>Welcome to the show.</p>
+<article data-url="https://example.com/one"><h2>First story</h2><p>One.</p>
+<h3>Details</h3><p>More about one.</p></article>
+<article data-title="Second story"><h2><a href="https://example.com/two">Two</a></h2><p>Two.</p></article>`
+
+func TestChaptersPerStory(t *testing.T) {
+	doc, err := MakeDocument(storiesHtml, Options{MaxChunkChars: 5000, Chapters: ChaptersStories})
+	assert.Nil(t, err)
+	assert.Equal(t, []Chapter{
+		{Title: "", Chunk: 0, Paragraph: 0},
+		{Title: "First story", URL: "https://example.com/one", Chunk: 1, Paragraph: 1},
+		{Title: "Second story", URL: "https://example.com/two", Chunk: 2, Paragraph: 3},
+	}, doc.Chapters)
+	assert.Equal(t, 3, len(doc.Chunks))
+	assert.Contains(t, doc.Chunks[2].Ssml, "Two.")
+}
+
+func TestChaptersPerSection(t *testing.T) {
+	doc, err := MakeDocument(storiesHtml, Options{MaxChunkChars: 5000, Chapters: ChaptersSections})
+	assert.Nil(t, err)
+	var titles []string
+	for _, c := range doc.Chapters {
+		titles = append(titles, c.Title)
+	}
+	assert.Equal(t, []string{"", "First story", "Details", "Second story"}, titles)
+	assert.Equal(t, 4, len(doc.Chunks))
+
+	doc, err = MakeDocument(storiesHtml, Options{MaxChunkChars: 5000})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(doc.Chapters))
+	assert.Equal(t, 1, len(doc.Chunks))
+}
+
+func TestChunksStartingAStory(t *testing.T) {
+	doc, err := MakeDocument(storiesHtml, Options{MaxChunkChars: 5000, Chapters: ChaptersStories})
+	assert.Nil(t, err)
+	var starts []Boundary
+	for _, c := range doc.Chunks {
+		starts = append(starts, c.Starts)
+	}
+	assert.Equal(t, []Boundary{BoundaryParagraph, BoundaryStory, BoundaryStory}, starts)
+}
+
+func TestExpandAudio(t *testing.T) {
+	doc, err := MakeDocument(`<speak><p>Before.</p><audio src="jingle.mp3" clipBegin="500ms" clipEnd="9s">Could not play the jingle</audio><p>After.</p></speak>`,
+		Options{MaxChunkChars: 5000, ExpandAudio: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(doc.Chunks))
+	assert.Equal(t, &Audio{Src: "jingle.mp3", ClipBegin: 500 * time.Millisecond, ClipEnd: 9 * time.Second, Fallback: "Could not play the jingle"}, doc.Chunks[1].Audio)
+	assert.Equal(t, "<speak>Could not play the jingle</speak>", doc.Chunks[1].Ssml)
+	assert.Nil(t, doc.Chunks[2].Audio)
+	assert.Contains(t, doc.Chunks[2].Ssml, "After.")
+
+	_, err = MakeDocument(`<speak><audio src="jingle.mp3" clipEnd="nine">x</audio></speak>`, Options{MaxChunkChars: 5000, ExpandAudio: true})
+	assert.NotNil(t, err)
+}
+
+func TestExpandAudioAtTheEnd(t *testing.T) {
+	doc, err := MakeDocument(`<speak><p>Before.</p><audio src="jingle.mp3">Could not play the jingle</audio></speak>`,
+		Options{MaxChunkChars: 5000, ExpandAudio: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(doc.Chunks))
+	assert.NotNil(t, doc.Chunks[1].Audio)
+}
+
+func TestBoundaries(t *testing.T) {
+	doc, err := MakeDocument(storiesHtml, Options{MaxChunkChars: 5000, Chapters: ChaptersStories, SectionPause: 1500 * time.Millisecond, StoryPause: 3 * time.Second})
+	assert.Nil(t, err)
+	var starts []Boundary
+	for _, c := range doc.Chunks {
+		starts = append(starts, c.Starts)
+	}
+	assert.Equal(t, []Boundary{BoundaryParagraph, BoundaryStory, BoundaryStory}, starts)
+	// The section inside the first story starts inside its chunk.
+	assert.Contains(t, doc.Chunks[1].Ssml, `<break time="700ms"></break><p>More about one.</p>`)
+	assert.Equal(t, 1500*time.Millisecond, doc.Chunks[1].Paragraphs[0].Pause)
+}
+
+func TestParagraphPause(t *testing.T) {
+	doc, err := MakeDocument(storiesHtml, Options{MaxChunkChars: 5000, Chapters: ChaptersStories, ParagraphPause: 500 * time.Millisecond, SectionPause: 1500 * time.Millisecond})
+	assert.Nil(t, err)
+	assert.Contains(t, doc.Chunks[0].Ssml, `<p>Welcome to the show.</p><break time="500ms"></break>`)
+	assert.Contains(t, doc.Chunks[1].Ssml, `<p>One.</p><break time="500ms"></break><break time="1000ms"></break><p>More about one.</p>`)
+	assert.Equal(t, 1500*time.Millisecond, doc.Chunks[1].Paragraphs[0].Pause)
+}
@@ -0,0 +1,97 @@
+package transcript
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/ssmltext"
+)
+
+// Timing is the time span in which the text of a <mark> is spoken, counted
+// from the start of the merged audio.
+type Timing struct {
+	Mark      string
+	Text      string
+	Paragraph int
+	Start     time.Duration
+	End       time.Duration
+}
+
+// Align returns the timings of the marks in the paragraphs of chunks.
+// starts holds the measured offset of every chunk in the merged audio,
+// durations its measured duration and timepoints the
+// times at which the marks were reached in the audio of every chunk, by mark
+// name. Marks without a time point are left out. A mark ends where the next
+// mark starts, or at the end of the speech of its paragraph.
+func Align(chunks []ssmltext.Chunk, starts, durations []time.Duration, timepoints []map[string]time.Duration) []Timing {
+	var timings []Timing
+	for i, chunk := range chunks {
+		if i >= len(starts) || i >= len(durations) || i >= len(timepoints) {
+			break
+		}
+		cues := chunkCues(chunk, 0, durations[i])
+		var chunkTimings []Timing
+		cue := 0
+		for _, p := range chunk.Paragraphs {
+			var paragraphEnd time.Duration
+			if len(p.Text) > 0 && cue < len(cues) {
+				paragraphEnd = cues[cue].End
+				cue++
+			}
+			first := len(chunkTimings)
+			for _, m := range p.Marks {
+				start, ok := timepoints[i][m.Name]
+				if !ok {
+					continue
+				}
+				chunkTimings = append(chunkTimings, Timing{Mark: m.Name, Text: m.Text, Paragraph: p.Index, Start: start})
+			}
+			for j := first; j < len(chunkTimings); j++ {
+				end := paragraphEnd
+				if j+1 < len(chunkTimings) {
+					end = chunkTimings[j+1].Start
+				}
+				// The estimated end of the paragraph can come before the
+				// time points that were measured.
+				if end < chunkTimings[j].Start {
+					end = chunkTimings[j].Start
+				}
+				chunkTimings[j].End = end
+			}
+		}
+		for _, t := range chunkTimings {
+			t.Start += starts[i]
+			t.End += starts[i]
+			timings = append(timings, t)
+		}
+	}
+	return timings
+}
+
+type jsonTiming struct {
+	Mark      string  `json:"mark"`
+	Text      string  `json:"text"`
+	Paragraph int     `json:"paragraph"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+}
+
+// MarshalAlignment formats the timings as json, with the times in seconds.
+func MarshalAlignment(timings []Timing) ([]byte, error) {
+	out := make([]jsonTiming, 0, len(timings))
+	for _, t := range timings {
+		out = append(out, jsonTiming{Mark: t.Mark, Text: t.Text, Paragraph: t.Paragraph, Start: t.Start.Seconds(), End: t.End.Seconds()})
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// WriteAlignment writes the timings as json to path, to highlight the text
+// as it is spoken.
+func WriteAlignment(path string, timings []Timing) error {
+	content, err := MarshalAlignment(timings)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,35 @@
+package transcript
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/ssmltext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlign(t *testing.T) {
+	marked := []ssmltext.Chunk{
+		{Paragraphs: []ssmltext.Paragraph{
+			{Index: 0, Text: "Four", Pause: time.Second, Marks: []ssmltext.Mark{{Name: "p0.w0", Text: "Four"}}},
+			{Index: 1, Text: "Hi there", Pause: time.Second, Marks: []ssmltext.Mark{{Name: "p1.w0", Text: "Hi"}, {Name: "p1.w1", Text: "there"}}},
+		}},
+		{Paragraphs: []ssmltext.Paragraph{
+			{Index: 2, Text: "Last", Marks: []ssmltext.Mark{{Name: "p2.w0", Text: "Last"}}},
+		}},
+	}
+	timings := Align(marked, []time.Duration{0, 5 * time.Second}, []time.Duration{5 * time.Second, 2 * time.Second}, []map[string]time.Duration{
+		{"p0.w0": 100 * time.Millisecond, "p1.w0": 2 * time.Second, "p1.w1": 3 * time.Second},
+		{"p2.w0": 50 * time.Millisecond},
+	})
+	assert.Equal(t, []Timing{
+		{Mark: "p0.w0", Text: "Four", Paragraph: 0, Start: 100 * time.Millisecond, End: time.Second},
+		{Mark: "p1.w0", Text: "Hi", Paragraph: 1, Start: 2 * time.Second, End: 3 * time.Second},
+		{Mark: "p1.w1", Text: "there", Paragraph: 1, Start: 3 * time.Second, End: 4 * time.Second},
+		{Mark: "p2.w0", Text: "Last", Paragraph: 2, Start: 5050 * time.Millisecond, End: 7 * time.Second},
+	}, timings)
+
+	content, err := MarshalAlignment(timings[:1])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"mark": "p0.w0", "text": "Four", "paragraph": 0, "start": 0.1, "end": 1}]`, string(content))
+}
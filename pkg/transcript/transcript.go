@@ -0,0 +1,128 @@
+// Package transcript writes WebVTT and SRT transcripts with a cue per
+// paragraph, timed from the offsets and durations of the synthesized chunks
+// in the merged audio.
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/ssmltext"
+)
+
+// Cue is a paragraph of text with the time span in which it is spoken.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// FromChunks returns a cue for every paragraph in chunks. starts holds the
+// measured offset of every chunk in the merged audio, which accounts for
+// overlapping joins, and durations its measured duration. Within a chunk the
+// pauses of the paragraphs are known exactly and the remaining time is
+// divided over the paragraphs by their length.
+func FromChunks(chunks []ssmltext.Chunk, starts, durations []time.Duration) []Cue {
+	var cues []Cue
+	for i, chunk := range chunks {
+		if i >= len(starts) || i >= len(durations) {
+			break
+		}
+		cues = append(cues, chunkCues(chunk, starts[i], durations[i])...)
+	}
+	return cues
+}
+
+func chunkCues(chunk ssmltext.Chunk, offset, duration time.Duration) []Cue {
+	var pauses time.Duration
+	var chars int
+	for _, p := range chunk.Paragraphs {
+		pauses += p.Pause
+		chars += utf8.RuneCountInString(p.Text)
+	}
+	// The API can return less silence than asked for, scale the pauses down
+	// when they do not fit in the chunk.
+	pauseScale := 1.0
+	if pauses > duration {
+		pauseScale = float64(duration) / float64(pauses)
+		pauses = duration
+	}
+	speech := duration - pauses
+
+	var cues []Cue
+	start := offset
+	for _, p := range chunk.Paragraphs {
+		var spoken time.Duration
+		if chars > 0 {
+			spoken = time.Duration(float64(speech) * float64(utf8.RuneCountInString(p.Text)) / float64(chars))
+		}
+		if len(p.Text) > 0 {
+			cues = append(cues, Cue{Start: start, End: start + spoken, Text: p.Text})
+		}
+		start += spoken + time.Duration(float64(p.Pause)*pauseScale)
+	}
+	return cues
+}
+
+// WriteVTT writes the cues as a WebVTT file.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprint(b, "WEBVTT\n")
+	for _, c := range cues {
+		fmt.Fprintf(b, "\n%s --> %s\n%s\n", timestamp(c.Start, "."), timestamp(c.End, "."), vttEscaper.Replace(cueText(c.Text)))
+	}
+	return b.Flush()
+}
+
+// WriteSRT writes the cues as a SubRip file.
+func WriteSRT(w io.Writer, cues []Cue) error {
+	b := bufio.NewWriter(w)
+	for i, c := range cues {
+		if i > 0 {
+			fmt.Fprint(b, "\n")
+		}
+		fmt.Fprintf(b, "%d\n%s --> %s\n%s\n", i+1, timestamp(c.Start, ","), timestamp(c.End, ","), strings.Replace(cueText(c.Text), "-->", "->", -1))
+	}
+	return b.Flush()
+}
+
+// vttEscaper escapes the characters that WebVTT cue text reserves for its
+// markup, which also breaks up any "-->".
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// cueText returns text without the blank lines that would end a cue.
+func cueText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteFile writes the cues to path with write, which is WriteVTT or
+// WriteSRT.
+func WriteFile(path string, cues []Cue, write func(io.Writer, []Cue) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, cues); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// timestamp formats d as hh:mm:ss.mmm, with the given separator before the
+// milliseconds.
+func timestamp(d time.Duration, separator string) string {
+	ms := int64(d / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
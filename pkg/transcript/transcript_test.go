@@ -0,0 +1,74 @@
+package transcript
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/ssmltext"
+	"github.com/stretchr/testify/assert"
+)
+
+var chunks = []ssmltext.Chunk{
+	{Paragraphs: []ssmltext.Paragraph{
+		{Index: 0, Text: "Four", Pause: time.Second},
+		{Index: 1, Text: "Hi there", Pause: time.Second},
+	}},
+	{Paragraphs: []ssmltext.Paragraph{
+		{Index: 2, Text: "Last", Pause: 0},
+	}},
+}
+
+func TestFromChunks(t *testing.T) {
+	cues := FromChunks(chunks, []time.Duration{0, 5 * time.Second}, []time.Duration{5 * time.Second, 90 * time.Minute})
+	assert.Equal(t, []Cue{
+		{Start: 0, End: time.Second, Text: "Four"},
+		{Start: 2 * time.Second, End: 4 * time.Second, Text: "Hi there"},
+		{Start: 5 * time.Second, End: 5*time.Second + 90*time.Minute, Text: "Last"},
+	}, cues)
+}
+
+func TestCuesStartAtTheMeasuredOffsets(t *testing.T) {
+	// A crossfade of 10ms makes the second chunk start before the end of
+	// the first.
+	cues := FromChunks(chunks, []time.Duration{0, 4990 * time.Millisecond}, []time.Duration{5 * time.Second, time.Second})
+	assert.Equal(t, 4990*time.Millisecond, cues[2].Start)
+}
+
+func TestPausesAreScaledWhenTheyDoNotFit(t *testing.T) {
+	cues := FromChunks(chunks[:1], []time.Duration{0}, []time.Duration{time.Second})
+	assert.Equal(t, time.Duration(0), cues[1].End-cues[1].Start)
+	assert.Equal(t, 500*time.Millisecond, cues[1].Start)
+}
+
+func TestWriteVTT(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, WriteVTT(&b, FromChunks(chunks, []time.Duration{0, 5 * time.Second}, []time.Duration{5 * time.Second, 90 * time.Minute})))
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:01.000
+Four
+
+00:00:02.000 --> 00:00:04.000
+Hi there
+
+00:00:05.000 --> 01:30:05.000
+Last
+`, b.String())
+}
+
+func TestWriteSRT(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, WriteSRT(&b, []Cue{{Start: 1500 * time.Millisecond, End: 61 * time.Second, Text: "Hello"}, {Start: 62 * time.Second, End: 63 * time.Second, Text: "Bye"}}))
+	assert.Equal(t, "1\n00:00:01,500 --> 00:01:01,000\nHello\n\n2\n00:01:02,000 --> 00:01:03,000\nBye\n", b.String())
+}
+
+func TestCueTextIsEscaped(t *testing.T) {
+	cues := []Cue{{Start: 0, End: time.Second, Text: "AT&T says a < b --> c\n\nand more"}}
+	var b bytes.Buffer
+	assert.Nil(t, WriteVTT(&b, cues))
+	assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nAT&amp;T says a &lt; b --&gt; c\nand more\n", b.String())
+	b.Reset()
+	assert.Nil(t, WriteSRT(&b, cues))
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,000\nAT&T says a < b -> c\nand more\n", b.String())
+}
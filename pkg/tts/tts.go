@@ -0,0 +1,137 @@
+// Package tts synthesizes speech with the v1beta1 REST API of Google Cloud
+// Text-to-Speech, which can return the time points of the <mark> elements in
+// the SSML. The v1 gRPC client does not support time points.
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// DefaultEndpoint is the address of the Text-to-Speech API.
+const DefaultEndpoint = "https://texttospeech.googleapis.com/"
+
+// Client calls the v1beta1 REST API.
+type Client struct {
+	HTTPClient *http.Client
+	Endpoint   string
+}
+
+// Request describes the speech to synthesize.
+type Request struct {
+	Ssml          string
+	LanguageCode  string
+	Voice         string
+	AudioEncoding string
+	Pitch         float64
+	SpeakingRate  float64
+	// Timepoints requests the time points of the <mark> elements.
+	Timepoints bool
+}
+
+// Response holds the synthesized audio and the time points of the marks.
+type Response struct {
+	AudioContent []byte
+	Timepoints   []Timepoint
+}
+
+// Timepoint is the time at which a <mark> is reached in the audio, counted
+// from the start of the audio.
+type Timepoint struct {
+	MarkName string
+	Time     time.Duration
+}
+
+// NewClient returns a client that authenticates with the application
+// default credentials, unless opts say otherwise.
+func NewClient(ctx context.Context, opts ...option.ClientOption) (*Client, error) {
+	opts = append([]option.ClientOption{
+		option.WithEndpoint(DefaultEndpoint),
+		option.WithScopes("https://www.googleapis.com/auth/cloud-platform"),
+	}, opts...)
+	httpClient, endpoint, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{HTTPClient: httpClient, Endpoint: endpoint}, nil
+}
+
+type synthesizeRequest struct {
+	Input struct {
+		Ssml string `json:"ssml"`
+	} `json:"input"`
+	Voice struct {
+		LanguageCode string `json:"languageCode"`
+		Name         string `json:"name,omitempty"`
+	} `json:"voice"`
+	AudioConfig struct {
+		AudioEncoding string  `json:"audioEncoding"`
+		Pitch         float64 `json:"pitch,omitempty"`
+		SpeakingRate  float64 `json:"speakingRate,omitempty"`
+	} `json:"audioConfig"`
+	EnableTimePointing []string `json:"enableTimePointing,omitempty"`
+}
+
+type synthesizeResponse struct {
+	AudioContent []byte `json:"audioContent"`
+	Timepoints   []struct {
+		MarkName    string  `json:"markName"`
+		TimeSeconds float64 `json:"timeSeconds"`
+	} `json:"timepoints"`
+}
+
+// Synthesize synthesizes the SSML in req.
+func (c *Client) Synthesize(ctx context.Context, req *Request) (*Response, error) {
+	var body synthesizeRequest
+	body.Input.Ssml = req.Ssml
+	body.Voice.LanguageCode = req.LanguageCode
+	body.Voice.Name = req.Voice
+	body.AudioConfig.AudioEncoding = req.AudioEncoding
+	body.AudioConfig.Pitch = req.Pitch
+	body.AudioConfig.SpeakingRate = req.SpeakingRate
+	if req.Timepoints {
+		body.EnableTimePointing = []string{"SSML_MARK"}
+	}
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("POST", strings.TrimSuffix(c.Endpoint, "/")+"/v1beta1/text:synthesize", bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.HTTPClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	respContent, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Text-to-Speech returned %s: %s", httpResp.Status, respContent)
+	}
+	var parsed synthesizeResponse
+	if err := json.Unmarshal(respContent, &parsed); err != nil {
+		return nil, fmt.Errorf("Failed to parse the Text-to-Speech response. Error: %s", err)
+	}
+	resp := &Response{AudioContent: parsed.AudioContent}
+	for _, tp := range parsed.Timepoints {
+		resp.Timepoints = append(resp.Timepoints, Timepoint{
+			MarkName: tp.MarkName,
+			Time:     time.Duration(tp.TimeSeconds * float64(time.Second)),
+		})
+	}
+	return resp, nil
+}
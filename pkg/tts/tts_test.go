@@ -0,0 +1,60 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer answers synthesize requests like the Text-to-Speech API, with
+// a time point every 250ms for the marks in the SSML.
+func fakeServer(t *testing.T, received *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta1/text:synthesize", r.URL.Path)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(received))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"audioContent": []byte("ID3 audio"),
+			"timepoints": []map[string]interface{}{
+				{"markName": "p0.s0", "timeSeconds": 0.0},
+				{"markName": "p0.s1", "timeSeconds": 1.25},
+			},
+		})
+	}))
+}
+
+func TestSynthesizeWithTimepoints(t *testing.T) {
+	var received map[string]interface{}
+	server := fakeServer(t, &received)
+	defer server.Close()
+
+	client := &Client{HTTPClient: server.Client(), Endpoint: server.URL + "/"}
+	resp, err := client.Synthesize(context.Background(), &Request{
+		Ssml:          `<speak><mark name="p0.s0"/>One. <mark name="p0.s1"/>Two.</speak>`,
+		LanguageCode:  "en-US",
+		Voice:         "en-US-Wavenet-D",
+		AudioEncoding: "MP3",
+		Timepoints:    true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("ID3 audio"), resp.AudioContent)
+	assert.Equal(t, []Timepoint{{MarkName: "p0.s0", Time: 0}, {MarkName: "p0.s1", Time: 1250 * time.Millisecond}}, resp.Timepoints)
+	assert.Equal(t, []interface{}{"SSML_MARK"}, received["enableTimePointing"])
+	assert.Equal(t, "en-US-Wavenet-D", received["voice"].(map[string]interface{})["name"])
+}
+
+func TestSynthesizeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "Invalid SSML"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := &Client{HTTPClient: server.Client(), Endpoint: server.URL}
+	_, err := client.Synthesize(context.Background(), &Request{Ssml: "<speak>"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Invalid SSML")
+}
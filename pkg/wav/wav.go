@@ -0,0 +1,245 @@
+// Package wav reads and writes 16-bit PCM WAV files, the format of the
+// LINEAR16 audio returned by the text-to-speech API.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/alexandervantrijffel/hackernewseverywhere-cli/pkg/audiostats"
+)
+
+// ErrUnsupported is wrapped by the errors for WAV files with samples that
+// are not 16-bit PCM, which other tools can still decode.
+var ErrUnsupported = errors.New("Unsupported WAV")
+
+// Format describes the layout of the samples.
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// Audio holds interleaved 16-bit PCM samples.
+type Audio struct {
+	Format  Format
+	Samples []int16
+}
+
+const headerSize = 44
+
+// Frames returns the number of sample frames, that is samples per channel.
+func (a *Audio) Frames() int {
+	if a.Format.Channels == 0 {
+		return 0
+	}
+	return len(a.Samples) / a.Format.Channels
+}
+
+// Duration returns the playing time of the audio.
+func (a *Audio) Duration() time.Duration {
+	return FramesDuration(a.Frames(), a.Format.SampleRate)
+}
+
+// FramesDuration returns the playing time of a number of sample frames.
+func FramesDuration(frames, sampleRate int) time.Duration {
+	if sampleRate == 0 {
+		return 0
+	}
+	return time.Duration(frames) * time.Second / time.Duration(sampleRate)
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("%d Hz, %d channels", f.SampleRate, f.Channels)
+}
+
+// Decode reads a WAV file with 16-bit PCM samples.
+func Decode(r io.Reader) (*Audio, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("Not a RIFF WAVE file")
+	}
+	audio := &Audio{}
+	var haveFormat bool
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil, errors.New("WAV file has no data chunk")
+			}
+			return nil, err
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		switch id {
+		case "fmt ":
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, err
+			}
+			if len(body) < 16 {
+				return nil, errors.New("WAV fmt chunk is too short")
+			}
+			if tag := binary.LittleEndian.Uint16(body[0:2]); tag != 1 && tag != 0xFFFE {
+				return nil, fmt.Errorf("%w encoding %d, only PCM is supported", ErrUnsupported, tag)
+			}
+			if bits := binary.LittleEndian.Uint16(body[14:16]); bits != 16 {
+				return nil, fmt.Errorf("%w sample size of %d bits, only 16 bits is supported", ErrUnsupported, bits)
+			}
+			audio.Format.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			audio.Format.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("WAV data chunk comes before the fmt chunk")
+			}
+			// Some encoders stream WAV files and leave the size at 0 or at
+			// the maximum, read until the end in that case.
+			var data []byte
+			var err error
+			if size == 0 || size == 0xFFFFFFFF {
+				data, err = ioutil.ReadAll(r)
+			} else {
+				data = make([]byte, size)
+				var n int
+				n, err = io.ReadFull(r, data)
+				if err == io.ErrUnexpectedEOF {
+					data, err = data[:n], nil
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			audio.Samples = make([]int16, len(data)/2)
+			for i := range audio.Samples {
+				audio.Samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+			}
+			return audio, nil
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, size+size%2); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if size%2 == 1 {
+			if _, err := io.CopyN(ioutil.Discard, r, 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// ReadFile reads the WAV file at path.
+func ReadFile(path string) (*Audio, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	audio, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s. Error: %w", path, err)
+	}
+	return audio, nil
+}
+
+// Encode writes audio as a WAV file.
+func Encode(w io.Writer, audio *Audio) error {
+	dataSize := 2 * len(audio.Samples)
+	header := make([]byte, headerSize)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(headerSize-8+dataSize))
+	copy(header[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], 1)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(audio.Format.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(audio.Format.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(audio.Format.SampleRate*audio.Format.Channels*2))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(audio.Format.Channels*2))
+	binary.LittleEndian.PutUint16(header[34:36], 16)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	data := make([]byte, dataSize)
+	for i, s := range audio.Samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// WriteFile writes audio to a WAV file at path.
+func WriteFile(path string, audio *Audio) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, audio); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Merge writes the audio of the input files, which must all have the same
+// format, one after the other to a new WAV file at outpath. Returns the
+// durations and offsets of the inputs, the frames counted are sample frames.
+func Merge(outpath string, inpaths []string) (*audiostats.Result, error) {
+	return MergeWith(outpath, inpaths, Append)
+}
+
+// Joiner appends next to merged and returns the frame in merged at which
+// next starts, which is before the end of merged when they overlap.
+type Joiner func(merged, next *Audio) int
+
+// Append is the Joiner that puts the audio back to back.
+func Append(merged, next *Audio) int {
+	start := merged.Frames()
+	merged.Samples = append(merged.Samples, next.Samples...)
+	return start
+}
+
+// MergeWith merges like Merge, joining the inputs with join.
+func MergeWith(outpath string, inpaths []string, join Joiner) (*audiostats.Result, error) {
+	merged := &Audio{}
+	result := &audiostats.Result{}
+	for i, inpath := range inpaths {
+		audio, err := ReadFile(inpath)
+		if err != nil {
+			return nil, err
+		}
+		start := 0
+		if i == 0 {
+			merged.Format = audio.Format
+			merged.Samples = audio.Samples
+		} else if audio.Format != merged.Format {
+			return nil, fmt.Errorf("Cannot merge %s with format %s into audio with format %s", inpath, audio.Format, merged.Format)
+		} else {
+			start = join(merged, audio)
+		}
+		result.AddAt(audiostats.Input{
+			Path:     inpath,
+			Start:    FramesDuration(start, merged.Format.SampleRate),
+			Duration: audio.Duration(),
+			Frames:   audio.Frames(),
+			Bytes:    int64(2 * len(audio.Samples)),
+		})
+	}
+	if err := WriteFile(outpath, merged); err != nil {
+		return nil, err
+	}
+	result.Duration = merged.Duration()
+	result.Frames = merged.Frames()
+	result.AddBitRate(merged.Format.SampleRate*merged.Format.Channels*16, result.Frames)
+	result.Bytes = int64(headerSize + 2*len(merged.Samples))
+	return result, nil
+}
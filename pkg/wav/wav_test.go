@@ -0,0 +1,66 @@
+package wav
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	audio := &Audio{Format: Format{SampleRate: 24000, Channels: 1}, Samples: []int16{0, 1, -1, 32767, -32768}}
+	var b bytes.Buffer
+	assert.Nil(t, Encode(&b, audio))
+	assert.Equal(t, headerSize+10, b.Len())
+	decoded, err := Decode(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, audio, decoded)
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, Encode(&b, &Audio{Format: Format{SampleRate: 24000, Channels: 1}, Samples: []int16{0, 1}}))
+	data := b.Bytes()
+	// 24-bit samples.
+	data[34] = 24
+	_, err := Decode(bytes.NewReader(data))
+	assert.True(t, errors.Is(err, ErrUnsupported))
+	_, err = Decode(bytes.NewReader(data[:10]))
+	assert.False(t, errors.Is(err, ErrUnsupported))
+}
+
+func TestDuration(t *testing.T) {
+	audio := &Audio{Format: Format{SampleRate: 24000, Channels: 2}, Samples: make([]int16, 48000)}
+	assert.Equal(t, time.Second, audio.Duration())
+}
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wav")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	format := Format{SampleRate: 16000, Channels: 1}
+	first, second, out := filepath.Join(dir, "0.wav"), filepath.Join(dir, "1.wav"), filepath.Join(dir, "out.wav")
+	assert.Nil(t, WriteFile(first, &Audio{Format: format, Samples: []int16{1, 2}}))
+	assert.Nil(t, WriteFile(second, &Audio{Format: format, Samples: []int16{3}}))
+	result, err := Merge(out, []string{first, second})
+	assert.Nil(t, err)
+	merged, err := ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1, 2, 3}, merged.Samples)
+	assert.Equal(t, 3, result.Frames)
+	assert.Equal(t, FramesDuration(2, 16000), result.Inputs[1].Start)
+	assert.Equal(t, FramesDuration(3, 16000), result.Duration)
+	assert.Equal(t, map[int]int{256000: 3}, result.BitRates)
+	info, err := os.Stat(out)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Size(), result.Bytes)
+
+	assert.Nil(t, WriteFile(second, &Audio{Format: Format{SampleRate: 24000, Channels: 1}, Samples: []int16{3}}))
+	_, err = Merge(out, []string{first, second})
+	assert.NotNil(t, err)
+}